@@ -337,6 +337,14 @@ func (c *client) assemblePlayer(jar recordjar.Jar) {
 	if _, found := jar[0]["DAMAGE"]; !found {
 		jar[0]["DAMAGE"] = []byte("2+2")
 	}
+	// Upgrade if no carrying capacity
+	if _, found := jar[0]["CAPACITY"]; !found {
+		jar[0]["CAPACITY"] = []byte("100")
+	}
+	// Upgrade if no mana for using abilities
+	if _, found := jar[0]["MANA"]; !found {
+		jar[0]["MANA"] = []byte("AFTER→30S MAXIMUM→20 RESTORE→2")
+	}
 	// Upgrade if no combat actions
 	if _, found := jar[0]["ONCOMBAT"]; !found {
 		jar[0]["ONCOMBAT"] = []byte(`
@@ -463,9 +471,15 @@ func (c *client) createPlayer() {
 	c.Int[core.HealthRestore] = 2
 	c.Int[core.HealthCurrent] = 30
 	c.Int[core.HealthMaximum] = 30
+	c.Int[core.ManaAfter] = (30 * time.Second).Nanoseconds()
+	c.Int[core.ManaRestore] = 2
+	c.Int[core.ManaCurrent] = 20
+	c.Int[core.ManaMaximum] = 20
 	c.Int[core.Armour] = 10
 	c.Int[core.DamageFixed] = 2
 	c.Int[core.DamageRandom] = 2
+	c.Int[core.Level] = 1
+	c.Int[core.Capacity] = 100
 	c.Any[core.OnCombat] = []string{
 		"[%A] lash[/es] out at [%d] hitting [%d.them] with random blows.",
 		"[%A] punch[/es] [%d] winding [%d.them].",
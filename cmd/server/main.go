@@ -11,6 +11,8 @@ import (
 	"math/rand"
 	"net"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"code.wolfmud.org/WolfMUD.git/client"
@@ -89,7 +91,9 @@ func main() {
 		}
 
 		stats.Config(c)
-		core.Config(c)
+		if err := core.Config(c); err != nil {
+			log.Fatalf("Configuration error: %s", err)
+		}
 		world.Config(c)
 		quota.Config(c, time.Now)
 		client.Config(c)
@@ -103,6 +107,16 @@ func main() {
 	world.Load()
 	core.BWL.Unlock()
 
+	// Save players and take a final world snapshot when shutting down
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		log.Printf("Shutting down, received signal: %s", <-sig)
+		world.Shutdown()
+		os.Exit(0)
+	}()
+	world.StartSnapshots()
+
 	quota.Status()
 
 	server := net.JoinHostPort(cfg.host, cfg.port)
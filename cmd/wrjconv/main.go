@@ -0,0 +1,172 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.wolfmud.org/WolfMUD.git/core"
+	"code.wolfmud.org/WolfMUD.git/recordjar"
+)
+
+const help = `
+wrjconv is a utility for converting WolfMUD recordjar (.wrj) files, such as
+zone and player files, to and from JSON and YAML. For example:
+
+  > wrjconv -to json data/zones/zinara.wrj > zinara.json
+  > wrjconv -o zinara.yaml data/zones/zinara.wrj
+  > wrjconv -o zinara.wrj zinara.json
+  > wrjconv -from yaml -to wrj < zinara.yaml
+
+If a format is not given using -from or -to it is taken from the extension of
+the input or output file: .wrj, .json, .yaml or .yml. Without an input file
+the standard input is read. Without an output file the standard output is
+written to.
+
+Each record is converted to a JSON object or YAML mapping. The field names are
+used as keys and the field data as string values. Field data is not
+interpreted, so lists and pairs keep their recordjar encoding. For example an
+Exits field would have the value "E→L3 SE→L4 S→L2". The free text section is
+written as a normal field using the name given by -f. Converting a file to
+JSON or YAML and back again results in the same records.
+
+Comments in .wrj files are discarded unless -c is given, in which case they
+are kept as fields prefixed with '//'. For example comments before a Name field
+are kept as the field '//NAME'. When writing .wrj files comment fields are
+written out as comments again. The wrjfmt utility can be used to tidy the
+formatting of written .wrj files.
+`
+
+func Usage() {
+	o := flag.CommandLine.Output()
+	fmt.Fprintf(o, "Usage of %s:\n", filepath.Base(os.Args[0]))
+	fmt.Fprint(o, "\n  wrjconv [-from FORMAT] [-to FORMAT] [-o FILE] [-c] [-f FIELD] [FILE]\n\n")
+	flag.PrintDefaults()
+	fmt.Fprint(o, help)
+}
+
+func main() {
+	flag.Usage = Usage
+	var (
+		from     = flag.String("from", "", "input format: wrj, json or yaml")
+		to       = flag.String("to", "", "output format: wrj, json or yaml")
+		output   = flag.String("o", "", "output file to write")
+		comments = flag.Bool("c", false, "keep comments when reading .wrj files")
+		freetext = flag.String("f", "description", "field name for the free text section")
+	)
+	flag.Parse()
+
+	if flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	in, input := io.Reader(os.Stdin), ""
+	if flag.NArg() == 1 {
+		input = flag.Arg(0)
+		f, err := os.Open(input)
+		if err != nil {
+			fail(err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	if *from == "" {
+		*from = format(input)
+	}
+	if *to == "" {
+		*to = format(*output)
+	}
+
+	var (
+		j   recordjar.Jar
+		err error
+	)
+	switch *from {
+	case "wrj":
+		j, err = readWRJ(in, *freetext, *comments)
+	case "json":
+		j, err = recordjar.ReadJSON(in)
+	case "yaml":
+		j, err = recordjar.ReadYAML(in)
+	default:
+		fail(fmt.Errorf("unknown or missing input format %q, use -from", *from))
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	buf := &bytes.Buffer{}
+	switch *to {
+	case "wrj":
+		j.Write(buf, *freetext, core.PreferredOrdering())
+	case "json":
+		err = j.WriteJSON(buf)
+	case "yaml":
+		err = j.WriteYAML(buf)
+	default:
+		fail(fmt.Errorf("unknown or missing output format %q, use -to", *to))
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	if *output == "" {
+		_, err = buf.WriteTo(os.Stdout)
+	} else {
+		err = os.WriteFile(*output, buf.Bytes(), 0660)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+// format returns the format for a file based on its extension. If the format
+// cannot be determined an empty string is returned.
+func format(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".wrj":
+		return "wrj"
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	return ""
+}
+
+// readWRJ reads a recordjar, optionally preserving comments.
+func readWRJ(in io.Reader, freetext string, comments bool) (recordjar.Jar, error) {
+	j := recordjar.Jar{}
+	d := recordjar.NewDecoder(in, freetext)
+	if comments {
+		d.PreserveComments()
+	}
+	for {
+		r, err := d.Next()
+		if r != nil {
+			j = append(j, r)
+		}
+		if err == io.EOF {
+			return j, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "wrjconv: %s\n", err)
+	os.Exit(1)
+}
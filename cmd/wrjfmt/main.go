@@ -0,0 +1,253 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"code.wolfmud.org/WolfMUD.git/core"
+	"code.wolfmud.org/WolfMUD.git/recordjar"
+)
+
+const help = `
+wrjfmt is a utility for formatting WolfMUD recordjar (.wrj) files, such as
+zone files. Records are rewritten so that:
+
+  - field names are right aligned on the field name/data separator
+  - fields are in the same preferred order the server uses when saving
+  - field names are spelt as the server would spell them
+  - continuation lines are indented consistently, with list separators ': '
+    outdented to line up with the field name/data separator
+  - free text sections are wrapped to fit within 78 columns
+
+Comments are kept and are written before the field they preceded, indented so
+that the comment delimiter '//' lines up with the field name/data separator.
+
+Without any paths wrjfmt reads from standard input and writes to standard
+output. Given a file wrjfmt formats that file. Given a directory wrjfmt
+formats all .wrj files in that directory, recursively. By default formatted
+files are written to standard output. For example:
+
+  > wrjfmt data/zones/zinara.wrj
+  > wrjfmt -l data/zones
+  > wrjfmt -d data/zones/zinara.wrj
+  > wrjfmt -w data/zones
+
+When using -l or -d wrjfmt exits with a non-zero status if any files are not
+formatted, making it suitable for use in scripts and CI checks.
+`
+
+var (
+	list     = flag.Bool("l", false, "list files whose formatting differs from wrjfmt's")
+	diff     = flag.Bool("d", false, "display diffs instead of rewriting files")
+	write    = flag.Bool("w", false, "write result to source file instead of stdout")
+	freetext = flag.String("f", "description", "field name for the free text section")
+)
+
+// listSep matches white-space surrounding a list separator in field data.
+// Continuation lines are joined by a single space when read, so a list
+// separator at the start of a continuation line ends up inline.
+var listSep = regexp.MustCompile(`\s+:\s+`)
+
+// listFields are the fields decoded as lists by the server, in upper case.
+// Only these fields have list separators put back at the start of
+// continuation lines, a colon in any other field is part of the data.
+var listFields = map[string]bool{}
+
+func init() {
+	for _, field := range core.ListFields() {
+		listFields[strings.ToUpper(field)] = true
+	}
+}
+
+func Usage() {
+	o := flag.CommandLine.Output()
+	fmt.Fprintf(o, "Usage of %s:\n", filepath.Base(os.Args[0]))
+	fmt.Fprint(o, "\n  wrjfmt [-l] [-d] [-w] [-f FIELD] [PATH...]\n\n")
+	flag.PrintDefaults()
+	fmt.Fprint(o, help)
+}
+
+func main() {
+	flag.Usage = Usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "Cannot use -w with standard input")
+			os.Exit(2)
+		}
+		if err := process("<standard input>", os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		os.Exit(exitCode)
+	}
+
+	for _, root := range flag.Args() {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			switch {
+			case err != nil:
+				return err
+			case d.IsDir():
+				return nil
+			case path != root && filepath.Ext(path) != ".wrj":
+				return nil
+			}
+			return processFile(path)
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 2
+		}
+	}
+	os.Exit(exitCode)
+}
+
+// exitCode is set to 1 if -l or -d finds unformatted files, 2 on error.
+var exitCode = 0
+
+// processFile formats a single file. Files named directly on the command line
+// are always processed. Files found while walking a directory are only
+// processed if they have a .wrj extension.
+func processFile(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return process(filename, f, os.Stdout)
+}
+
+// process formats the recordjar read from in. Depending on the flags given
+// the result is written to out, written back to the named file, listed or
+// diffed against the original.
+func process(filename string, in io.Reader, out io.Writer) error {
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	res, err := format(src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	if !*list && !*diff && !*write {
+		_, err = out.Write(res)
+		return err
+	}
+
+	if bytes.Equal(src, res) {
+		return nil
+	}
+
+	if *list {
+		fmt.Fprintln(out, filename)
+		exitCode = 1
+	}
+	if *diff {
+		d, err := diffBytes(filename, src, res)
+		if err != nil {
+			return fmt.Errorf("computing diff: %w", err)
+		}
+		out.Write(d)
+		exitCode = 1
+	}
+	if *write {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		if err = os.WriteFile(filename, res, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// format reads all of the records in src, preserving comments, and writes
+// them back out in the canonical format.
+func format(src []byte) ([]byte, error) {
+	ft := strings.ToUpper(*freetext)
+	buf := &bytes.Buffer{}
+
+	d := recordjar.NewDecoder(bytes.NewReader(src), ft)
+	d.PreserveComments()
+	e := recordjar.NewEncoder(buf, ft, core.PreferredOrdering())
+
+	for {
+		r, err := d.Next()
+		if r != nil {
+			for field, data := range r {
+				if listFields[field] {
+					r[field] = listSep.ReplaceAll(data, []byte("\n: "))
+				}
+			}
+			e.Encode(r)
+		}
+		if err == io.EOF {
+			return buf.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// diffBytes returns a unified diff of the original and formatted data using
+// the system's diff command.
+func diffBytes(filename string, b1, b2 []byte) ([]byte, error) {
+	f1, err := writeTemp(b1)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f1)
+
+	f2, err := writeTemp(b2)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f2)
+
+	data, err := exec.Command(
+		"diff", "-u",
+		"--label", filename+".orig", "--label", filename,
+		f1, f2,
+	).CombinedOutput()
+
+	// diff exits with 1 if the files differ, which is not an error for us
+	if len(data) > 0 {
+		return data, nil
+	}
+	return data, err
+}
+
+// writeTemp writes data to a new temporary file returning its name.
+func writeTemp(data []byte) (string, error) {
+	f, err := os.CreateTemp("", "wrjfmt")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -36,11 +36,18 @@ const DefaultCfg = `// Built-in configuration
 		Server.IdleTimeout:   10m
 		Server.MaxPlayers:    1024
 		Stats.Rate:           10s
+		Snapshot.Rate:        5m
 		Inventory.CrowdSize:  11
+		Death.PlayerDrop:     NONE
+		Death.CorpseCleanup:  5m
+		PvP.Policy:           CONSENSUAL
+		PvP.Cooldown:         5m
+		Hunger.Rate:          0s
 		Login.AccountLength:  10
 		Login.PasswordLength: 10
 		Login.SaltLength:     32
 		Login.Timeout:        1m
+		Debug.CombatLog:      combat.jsonl
 
 
 WolfMUD Copyright 1984-2021 Andrew 'Diddymus' Rolfe
@@ -59,7 +66,11 @@ type Config struct {
 	Server    Server
 	Quota     Quota
 	Stats     Stats
+	Snapshot  Snapshot
 	Inventory Inventory
+	Death     Death
+	PvP       PvP
+	Hunger    Hunger
 	Login     Login
 	Debug     Debug
 	Greeting  string
@@ -85,10 +96,29 @@ type Stats struct {
 	GC   bool
 }
 
+type Snapshot struct {
+	Rate    time.Duration
+	Discard bool
+}
+
 type Inventory struct {
 	CrowdSize int
 }
 
+type Death struct {
+	PlayerDrop    string // ALL, NONE or RANDOM
+	CorpseCleanup time.Duration
+}
+
+type PvP struct {
+	Policy   string // FORBIDDEN, CONSENSUAL or FREE
+	Cooldown time.Duration
+}
+
+type Hunger struct {
+	Rate time.Duration // 0 to disable
+}
+
 type Login struct {
 	AccountLength  int
 	PasswordLength int
@@ -97,11 +127,13 @@ type Login struct {
 }
 
 type Debug struct {
-	LongLog bool
-	Panic   bool
-	Events  bool
-	Things  bool
-	Quota   bool
+	LongLog   bool
+	Panic     bool
+	Events    bool
+	Things    bool
+	Quota     bool
+	Combat    bool
+	CombatLog string // Combat log file, relative to DataPath if not absolute
 }
 
 // Default returns the default, built-in server configuration. Failure to
@@ -180,10 +212,32 @@ func (c Config) Read(r io.Reader) (Config, error) {
 			case "STATS.GC":
 				c.Stats.GC = decode.Boolean(data)
 
+			// Snapshot settings
+			case "SNAPSHOT.RATE":
+				c.Snapshot.Rate = decode.Duration(data)
+			case "SNAPSHOT.DISCARD":
+				c.Snapshot.Discard = decode.Boolean(data)
+
 			// Inventory settings
 			case "INVENTORY.CROWDSIZE":
 				c.Inventory.CrowdSize = decode.Integer(data)
 
+			// Death settings
+			case "DEATH.PLAYERDROP":
+				c.Death.PlayerDrop = decode.Keyword(data)
+			case "DEATH.CORPSECLEANUP":
+				c.Death.CorpseCleanup = decode.Duration(data)
+
+			// PvP settings
+			case "PVP.POLICY":
+				c.PvP.Policy = decode.Keyword(data)
+			case "PVP.COOLDOWN":
+				c.PvP.Cooldown = decode.Duration(data)
+
+			// Hunger settings
+			case "HUNGER.RATE":
+				c.Hunger.Rate = decode.Duration(data)
+
 			// Login settings
 			case "LOGIN.ACCOUNTLENGTH":
 				c.Login.AccountLength = decode.Integer(data)
@@ -205,6 +259,10 @@ func (c Config) Read(r io.Reader) (Config, error) {
 				c.Debug.Things = decode.Boolean(data)
 			case "DEBUG.QUOTA":
 				c.Debug.Quota = decode.Boolean(data)
+			case "DEBUG.COMBAT":
+				c.Debug.Combat = decode.Boolean(data)
+			case "DEBUG.COMBATLOG":
+				c.Debug.CombatLog = decode.String(data)
 
 			case "GREETING":
 				c.Greeting = string(text.Colorize(data))
@@ -0,0 +1,434 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package core
+
+import (
+	"log"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.wolfmud.org/WolfMUD.git/recordjar"
+	"code.wolfmud.org/WolfMUD.git/recordjar/decode"
+	"code.wolfmud.org/WolfMUD.git/text"
+)
+
+// ability is something, such as a spell, that players and NPCs can use with
+// the CAST or USE commands. Using an ability costs mana and the ability
+// cannot be used again until its cool-down has passed.
+type ability struct {
+	ref          string        // Keyword used to cast the ability ("FIREBALL")
+	name         string        // Name of the ability used in messages
+	level        int64         // Experience level needed to use the ability
+	cost         int64         // Mana used by the ability
+	cooldown     time.Duration // Time before the ability can be used again
+	target       string        // Who the ability can be used on: SELF, FOE or ANY
+	effect       string        // Effect of the ability: DAMAGE, HEAL or BUFF
+	damageFixed  int64         // Fixed damage for DAMAGE, or increase for BUFF
+	damageRandom int64         // Random damage for DAMAGE, or increase for BUFF
+	damageType   string        // Type of damage for DAMAGE ("FIRE")
+	heal         int64         // Health restored for HEAL
+	armour       int64         // Increase in armour for BUFF
+	duration     time.Duration // How long a BUFF lasts
+	message      string        // Message when used on someone else
+	selfMessage  string        // Message when used on self
+}
+
+// abilities are the abilities that can be used, keyed by the ability's
+// keyword. Set by SetAbilities.
+var abilities = map[string]*ability{}
+
+// SetAbilities sets the abilities that can be used from the passed recordjar,
+// one record per ability. Records with an unknown target or effect, or where
+// the effect cannot be used on the target, are logged and ignored.
+// SetAbilities should be called before any players enter the world.
+func SetAbilities(jar recordjar.Jar) {
+	abilities = map[string]*ability{}
+	for _, r := range jar {
+		ref := decode.Keyword(r["ABILITY"])
+		if ref == "" {
+			continue
+		}
+		a := &ability{
+			ref:         ref,
+			name:        decode.String(r["NAME"]),
+			level:       int64(decode.Integer(r["LEVEL"])),
+			cost:        int64(decode.Integer(r["COST"])),
+			cooldown:    decode.Duration(r["COOLDOWN"]),
+			target:      decode.Keyword(r["TARGET"]),
+			effect:      decode.Keyword(r["EFFECT"]),
+			damageType:  decode.Keyword(r["DAMAGETYPE"]),
+			heal:        int64(decode.Integer(r["HEAL"])),
+			armour:      int64(decode.Integer(r["ARMOUR"])),
+			duration:    decode.Duration(r["DURATION"]),
+			message:     decode.String(r["MESSAGE"]),
+			selfMessage: decode.String(r["SELFMESSAGE"]),
+		}
+		if len(r["DAMAGE"]) > 0 {
+			fixed, random := decode.DoubleInteger(r["DAMAGE"])
+			a.damageFixed, a.damageRandom = int64(fixed), int64(random)
+		}
+		if a.name == "" {
+			a.name = strings.ToLower(ref)
+		}
+		if a.message == "" {
+			a.message = "[%A] use[/s] " + a.name + " on [%d]."
+		}
+		if a.selfMessage == "" {
+			a.selfMessage = "[%A] use[/s] " + a.name + "."
+		}
+
+		switch {
+		case a.target != "SELF" && a.target != "FOE" && a.target != "ANY":
+			log.Printf("Ability %s has unknown target %q, ignored", ref, a.target)
+		case a.effect != "DAMAGE" && a.effect != "HEAL" && a.effect != "BUFF":
+			log.Printf("Ability %s has unknown effect %q, ignored", ref, a.effect)
+		case (a.effect == "DAMAGE") != (a.target == "FOE"):
+			log.Printf("Ability %s: %s cannot be used on target %s, ignored", ref, a.effect, a.target)
+		case a.effect == "BUFF" && a.duration <= 0:
+			log.Printf("Ability %s: BUFF needs a DURATION, ignored", ref)
+		default:
+			abilities[ref] = a
+		}
+	}
+	log.Printf("Loaded abilities: %d", len(abilities))
+}
+
+// known returns the abilities who can use at their current experience level,
+// sorted by keyword.
+func known(who *Thing) []*ability {
+	lvl := currentLevel(who)
+	list := []*ability{}
+	for _, a := range abilities {
+		if a.level <= lvl {
+			list = append(list, a)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ref < list[j].ref
+	})
+	return list
+}
+
+// cooling returns how long before who can use the ability with the passed
+// keyword again, or 0 if who can use the ability now.
+func cooling(who *Thing, ref string) time.Duration {
+	for _, cd := range who.Any[Cooldowns] {
+		if r, at := splitDue(cd); r == ref {
+			if wait := time.Until(time.Unix(0, at)); wait > 0 {
+				return wait
+			}
+		}
+	}
+	return 0
+}
+
+// startCooldown starts the cool-down for who using the passed ability and
+// reschedules who's Cooldown event.
+func startCooldown(who *Thing, a *ability) {
+	if a.cooldown <= 0 {
+		return
+	}
+	cds := who.Any[Cooldowns][:0]
+	for _, cd := range who.Any[Cooldowns] {
+		if ref, _ := splitDue(cd); ref != a.ref {
+			cds = append(cds, cd)
+		}
+	}
+	at := time.Now().Add(a.cooldown).UnixNano()
+	who.Any[Cooldowns] = append(cds, a.ref+"→"+strconv.FormatInt(at, 10))
+	scheduleCooldown(who)
+}
+
+// scheduleCooldown schedules who's Cooldown event for when the next cool-down
+// ends. If who has no cool-downs the Cooldown event is cancelled.
+func scheduleCooldown(who *Thing) {
+	next := int64(0)
+	for _, cd := range who.Any[Cooldowns] {
+		if _, at := splitDue(cd); next == 0 || at < next {
+			next = at
+		}
+	}
+	if next == 0 {
+		who.Cancel(Cooldown)
+		delete(who.Any, Cooldowns)
+		delete(who.Int, CooldownAfter)
+		return
+	}
+	wait := time.Until(time.Unix(0, next)).Nanoseconds()
+	if wait < 1 {
+		wait = 1
+	}
+	who.Int[CooldownAfter] = wait
+	who.Cancel(Cooldown)
+	who.Schedule(Cooldown)
+}
+
+// Cooldown ends the cool-downs of abilities that are due, telling the actor
+// they can use the abilities again. It is run for the actor's Cooldown event.
+func (s *state) Cooldown() {
+	s.actor.Cancel(Cooldown)
+	now := time.Now().UnixNano()
+	cds := s.actor.Any[Cooldowns][:0]
+	for _, cd := range s.actor.Any[Cooldowns] {
+		ref, at := splitDue(cd)
+		if at > now {
+			cds = append(cds, cd)
+			continue
+		}
+		if a := abilities[ref]; a != nil {
+			s.Msg(s.actor, text.Good, "You can use ", a.name, " again.")
+		}
+	}
+	s.actor.Any[Cooldowns] = cds
+	scheduleCooldown(s.actor)
+}
+
+// Mana restores the actor's mana over time, it is run for the actor's Mana
+// event.
+func (s *state) Mana() {
+	s.actor.Cancel(Mana)
+	if s.actor.Int[ManaCurrent] >= s.actor.Int[ManaMaximum] {
+		return
+	}
+
+	s.actor.Int[ManaCurrent] += s.actor.Int[ManaRestore]
+
+	if s.actor.Int[ManaCurrent] >= s.actor.Int[ManaMaximum] {
+		s.actor.Int[ManaCurrent] = s.actor.Int[ManaMaximum]
+		s.Msg(s.actor, text.Good, "Your mana is fully restored.")
+	} else {
+		s.actor.Schedule(Mana)
+	}
+
+	s.StatusUpdate(s.actor)
+}
+
+// Buff removes a buff from an ability when it wears off, it is run for the
+// actor's Buff event.
+func (s *state) Buff() {
+	name := s.actor.As[BuffName]
+	debuff(s.actor)
+	if name != "" {
+		s.Msg(s.actor, text.Info, "The effects of ", name, " wear off.")
+	}
+}
+
+// debuff removes any buff from an ability who has, without notifying them.
+func debuff(who *Thing) {
+	who.Cancel(Buff)
+	delete(who.As, BuffName)
+	delete(who.Int, BuffAfter)
+	delete(who.Int, BuffArmour)
+	delete(who.Int, BuffDamageFixed)
+	delete(who.Int, BuffDamageRandom)
+}
+
+// Cast uses one of the actor's abilities, such as a spell. For example:
+//
+//	CAST FIREBALL AT RAT
+//	USE HEAL ON DIDDYMUS
+//
+// Abilities used on foes target the actor's current opponent if no target is
+// given, using an ability on a foe the actor is not fighting starts a fight.
+// Other abilities target the actor if no target is given.
+func (s *state) Cast() {
+
+	if len(s.word) == 0 {
+		s.Msg(s.actor, text.Info, "You go to use... which ability?")
+		return
+	}
+
+	a := abilities[s.word[0]]
+	if a != nil && a.level > currentLevel(s.actor) {
+		a = nil
+	}
+	if a == nil {
+		s.Msg(s.actor, text.Bad, "You don't know how to use '", s.word[0], "'.")
+		return
+	}
+
+	words := s.word[1:]
+	if len(words) > 0 && (words[0] == "AT" || words[0] == "ON") {
+		words = words[1:]
+	}
+
+	where := s.actor.Ref[Where]
+	var who *Thing
+	if len(words) > 0 {
+		uid := Match(words, where)[0]
+		if who = where.Who[uid]; who == nil {
+			who = where.In[uid]
+		}
+		if who == nil {
+			s.Msg(s.actor, text.Bad, "You see no '", uid, "' here to use ", a.name, " on.")
+			return
+		}
+	}
+
+	switch {
+	case a.target == "FOE" && who == nil:
+		who = target(s.actor)
+	case a.target != "FOE" && who == nil:
+		who = s.actor
+	}
+
+	notify := len(where.Who) < cfg.crowdSize
+	fight := a.target == "FOE" && who != nil && !opposed(s.actor, who)
+
+	switch {
+	case who == nil:
+		s.Msg(s.actor, text.Bad, "Who do you want to use ", a.name, " on?")
+		return
+	case a.target == "SELF" && who != s.actor:
+		s.Msg(s.actor, text.Bad, "You can only use ", a.name, " on yourself.")
+		return
+	case a.target == "FOE" && who == s.actor:
+		s.Msg(s.actor, text.Bad, "You can't use ", a.name, " on yourself.")
+		return
+	case who.Is&(Player|NPC) == 0:
+		s.Msg(s.actor, text.Bad, "You can't use ", a.name, " on ", who.As[TheName], ".")
+		return
+	case a.effect == "HEAL" && who.Int[HealthCurrent] >= who.Int[HealthMaximum]:
+		if who == s.actor {
+			s.Msg(s.actor, text.Info, "You are already fully healthy.")
+		} else {
+			s.Msg(s.actor, text.Info, who.As[UTheName], " is already fully healthy.")
+		}
+		return
+	case cooling(s.actor, a.ref) > 0:
+		wait := cooling(s.actor, a.ref).Round(time.Second)
+		if wait < time.Second {
+			wait = time.Second
+		}
+		s.Msg(s.actor, text.Bad, "You can't use ", a.name, " again for another ", wait.String(), ".")
+		return
+	case s.actor.Int[ManaCurrent] < a.cost:
+		s.Msg(s.actor, text.Bad, "You don't have enough mana to use ", a.name, ".")
+		return
+	case fight && len(where.Who) >= cfg.crowdSize:
+		s.Msg(s.actor, text.Bad, "It's too crowded to start a fight here!")
+		return
+	case fight && !s.engage(who):
+		return
+	}
+
+	if a.cost > 0 {
+		s.actor.Int[ManaCurrent] -= a.cost
+		if s.actor.Event[Mana] == nil {
+			s.actor.Schedule(Mana)
+		}
+		s.StatusUpdate(s.actor)
+	}
+	startCooldown(s.actor, a)
+
+	switch a.effect {
+	case "DAMAGE":
+		s.castDamage(a, who, notify)
+		if fight && who.Int[HealthCurrent] > 0 {
+			s.rally(who)
+			s.rally(s.actor)
+		}
+	case "HEAL":
+		s.castMessage(a, who, notify)
+		who.Int[HealthCurrent] += a.heal
+		if who.Int[HealthCurrent] >= who.Int[HealthMaximum] {
+			who.Int[HealthCurrent] = who.Int[HealthMaximum]
+			who.Cancel(Health)
+		}
+		s.StatusUpdate(who)
+	case "BUFF":
+		s.castMessage(a, who, notify)
+		debuff(who)
+		who.As[BuffName] = a.name
+		who.Int[BuffArmour] = a.armour
+		who.Int[BuffDamageFixed] = a.damageFixed
+		who.Int[BuffDamageRandom] = a.damageRandom
+		who.Int[BuffAfter] = a.duration.Nanoseconds()
+		who.Schedule(Buff)
+	}
+}
+
+// castMessage sends the messages for the actor using the ability on who. The
+// messages are built using Message, giving the actor, who and any observers
+// their own view of what happened.
+func (s *state) castMessage(a *ability, who *Thing, notify bool) {
+	where := s.actor.Ref[Where]
+	if who == s.actor {
+		amsg, _, omsg := Message(s.actor, s.actor, a.selfMessage)
+		s.Msg(s.actor, text.Good, amsg)
+		if notify {
+			s.Msg(where, text.Info, omsg)
+		}
+		return
+	}
+	amsg, dmsg, omsg := Message(s.actor, who, a.message)
+	s.Msg(s.actor, text.Good, amsg)
+	if a.target == "FOE" {
+		s.Msg(who, text.Bad, dmsg)
+	} else {
+		s.Msg(who, text.Good, dmsg)
+	}
+	if notify {
+		s.Msg(where, text.Info, omsg)
+	}
+}
+
+// castDamage has the actor damage who, a foe, with the passed ability. The
+// damage is reduced, or increased, by who's resistance to the ability's
+// damage type. Unlike a melee attack the ability always hits. Observers are
+// only told if notify is true.
+func (s *state) castDamage(a *ability, who *Thing, notify bool) {
+
+	// Experience for killing who is based on who before damage
+	worth := attack(who) + defense(who)
+
+	damage := a.damageFixed
+	if a.damageRandom > 0 {
+		damage += rand.Int63n(a.damageRandom + 1)
+	}
+	damage -= damage * resistance(who, a.damageType) / 100
+
+	who.Int[HealthCurrent] -= damage
+	recordAttack(s.actor, who, 1, true, damage)
+
+	amsg, dmsg, omsg := Message(s.actor, who, a.message)
+	s.Msg(s.actor, text.Good, amsg)
+	s.Msg(who, text.Bad, dmsg)
+	if notify {
+		s.Msg(s.actor.Ref[Where], text.Info, omsg)
+	}
+
+	s.wounded(s.actor, who, worth)
+}
+
+// Abilities lists the abilities the actor can use, with their mana cost and
+// cool-down, and how long before any cooling down abilities can be used
+// again.
+func (s *state) Abilities() {
+	list := known(s.actor)
+	if len(list) == 0 {
+		s.Msg(s.actor, text.Info, "You don't know any abilities.")
+		return
+	}
+
+	s.Msg(s.actor, text.Info, "You can use:")
+	for _, a := range list {
+		line := "  " + a.ref + " - " + strconv.FormatInt(a.cost, 10) + " mana"
+		if a.cooldown > 0 {
+			line += ", cool-down " + a.cooldown.String()
+		}
+		if wait := cooling(s.actor, a.ref).Round(time.Second); wait > 0 {
+			line += " (ready in " + wait.String() + ")"
+		}
+		s.Msg(s.actor, line)
+	}
+	s.Msg(s.actor, text.Info, "You have ",
+		strconv.FormatInt(s.actor.Int[ManaCurrent], 10), "/",
+		strconv.FormatInt(s.actor.Int[ManaMaximum], 10), " mana.")
+}
@@ -9,6 +9,8 @@ import (
 	"fmt"
 	"math/big"
 	"math/rand"
+	"strconv"
+	"strings"
 	"time"
 
 	"code.wolfmud.org/WolfMUD.git/text"
@@ -16,6 +18,11 @@ import (
 
 var roundDuration = (3 * time.Second).Nanoseconds()
 
+// createCorpse returns a corpse for the passed, killed, NPC or player. The
+// corpse is a container holding the items the NPC was carrying. For players
+// the items held depend on the configured death policy, see dropOnDeath. Items
+// put into the corpse are no longer held, worn or wielded. A corpse holding
+// items takes longer to be cleaned up, giving time for it to be looted.
 func createCorpse(t *Thing) *Thing {
 	c := NewThing()
 	c.As[Name] = "the corpse of " + t.As[Name]
@@ -25,11 +32,26 @@ func createCorpse(t *Thing) *Thing {
 	c.As[Description] = t.As[Description]
 	c.Any[Alias] = append(c.Any[Alias], t.Any[Alias]...)
 	c.Any[Qualifier] = append(c.Any[Qualifier], t.Any[Qualifier]...)
+	c.Is |= Container
 	c.Ref[Where] = t.Ref[Where]
-	c.Ref[Where].In[c.As[UID]] = t
 	c.Int[CleanupAfter] = time.Duration(60 * time.Second).Nanoseconds()
 	c.As[OnCleanup] = c.As[UTheName] + " turns to dust."
 
+	for _, item := range t.In.Sort() {
+		if item.Is&Narrative == Narrative || !dropOnDeath(t) {
+			continue
+		}
+		delete(t.In, item.As[UID])
+		release(t, item)
+		delete(item.As, DynamicQualifier)
+		item = item.Spawn()
+		item.Ref[Where] = c
+		c.In[item.As[UID]] = item
+	}
+	if len(c.In) > 0 && cfg.corpseCleanup > c.Int[CleanupAfter] {
+		c.Int[CleanupDueIn] = cfg.corpseCleanup
+	}
+
 	// Replace original UID alias with "CORPSE" (new UID was added by NewThing)
 	for x, alias := range c.Any[Alias] {
 		if alias == t.As[UID] {
@@ -41,6 +63,26 @@ func createCorpse(t *Thing) *Thing {
 	return c
 }
 
+// playerDrops are the valid death policies for what players drop when they
+// are killed, see dropOnDeath.
+var playerDrops = []string{"ALL", "NONE", "RANDOM"}
+
+// dropOnDeath returns true if an item carried by the passed, killed, NPC or
+// player should be dropped into their corpse, otherwise false. NPCs always
+// drop their items. For players it depends on the configured death policy.
+func dropOnDeath(t *Thing) bool {
+	if t.Is&Player != Player {
+		return true
+	}
+	switch cfg.playerDrop {
+	case "ALL":
+		return true
+	case "RANDOM":
+		return rand.Intn(2) == 0
+	}
+	return false
+}
+
 func (s *state) Eval() {
 	if len(s.word) == 0 {
 		s.Msg(s.actor, text.Info, "Who do you want to evluate you chances against?")
@@ -64,6 +106,9 @@ func (s *state) Eval() {
 	}
 }
 
+// Attack starts the actor fighting someone. If the actor is already fighting
+// the new opponent is added to those they are fighting and becomes their
+// current target.
 func (s *state) Attack() {
 
 	if len(s.word) == 0 {
@@ -71,11 +116,6 @@ func (s *state) Attack() {
 		return
 	}
 
-	if len(s.actor.Any[Opponents]) > 0 {
-		s.Msg(s.actor, text.Bad, "You are already fighting!")
-		return
-	}
-
 	where := s.actor.Ref[Where]
 	if len(where.Who) >= cfg.crowdSize {
 		s.Msg(s.actor, text.Bad, "It's too crowded to start a fight here!")
@@ -98,44 +138,306 @@ func (s *state) Attack() {
 	case what.Is&(Player|NPC) == 0:
 		s.Msg(s.actor, text.Bad, "You cannot fight ", what.As[TheName], ".")
 		s.Msg(where, text.Info, s.actor.As[UName], " tries to attack ", what.As[Name], ".")
+	case s.actor.Ref[Opponent] == what:
+		s.Msg(s.actor, text.Bad, "You are already fighting ", what.As[TheName], "!")
+	case opposed(s.actor, what):
+		s.Msg(s.actor, text.Bad, "You are already fighting ", what.As[TheName], ", use TARGET to attack them instead.")
+	case s.engage(what):
+		s.Msg(s.actor, text.Good, "You attack ", what.As[TheName], "!")
+		s.Msg(what, text.Bad, s.actor.As[UTheName], " attacks you!")
+		s.Msg(where, text.Info, s.actor.As[UTheName], " attacks ", what.As[TheName], "!")
+		s.Log("%s attacked %s (%s)", s.actor.As[Name], what.As[Name], what.As[UID])
+		s.rally(what)
+		s.rally(s.actor)
+	}
+}
+
+// Assist has the actor join a fight, helping someone by attacking whoever
+// they are currently attacking.
+func (s *state) Assist() {
+
+	if len(s.word) == 0 {
+		s.Msg(s.actor, text.Info, "Who do you want to assist?")
+		return
+	}
+
+	where := s.actor.Ref[Where]
+	if len(where.Who) >= cfg.crowdSize {
+		s.Msg(s.actor, text.Bad, "It's too crowded to join a fight here!")
+		return
+	}
+
+	uids := Match(s.word, where)
+	uid := uids[0]
+	what := where.Who[uid]
+	if what == nil {
+		what = where.In[uid]
+	}
+
+	var foe *Thing
+	if what != nil {
+		foe = target(what)
+	}
+
+	switch {
+	case what == nil:
+		s.Msg(s.actor, text.Bad, "You see no '", uid, "' here to assist.")
+	case s.actor == what:
+		s.Msg(s.actor, text.Bad, "You can't assist yourself.")
+	case foe == nil:
+		s.Msg(s.actor, text.Bad, what.As[UTheName], " is not fighting anyone.")
+	case foe == s.actor:
+		s.Msg(s.actor, text.Bad, what.As[UTheName], " is fighting you!")
+	case s.actor.Ref[Opponent] == foe:
+		s.Msg(s.actor, text.Bad, "You are already fighting ", foe.As[TheName], "!")
+	case opposed(s.actor, foe):
+		s.Msg(s.actor, text.Bad, "You are already fighting ", foe.As[TheName], ", use TARGET to attack them instead.")
+	case s.engage(foe):
+		s.Msg(s.actor, text.Good, "You assist ", what.As[TheName], ", attacking ", foe.As[TheName], "!")
+		s.Msg(what, text.Good, s.actor.As[UTheName], " comes to your aid, attacking ", foe.As[TheName], "!")
+		s.Msg(foe, text.Bad, s.actor.As[UTheName], " attacks you!")
+		s.Msg(where, text.Info, s.actor.As[UTheName], " assists ", what.As[TheName], ", attacking ", foe.As[TheName], "!")
+		s.Log("%s assisted %s, attacking %s (%s)", s.actor.As[Name], what.As[Name], foe.As[Name], foe.As[UID])
+		s.rally(foe)
+	}
+}
+
+// Target switches the actor's attacks to another opponent they are already
+// fighting. Without an opponent the actor's current target is shown.
+func (s *state) Target() {
+
+	if len(s.actor.Any[Opponents]) == 0 {
+		s.Msg(s.actor, text.Info, "You are not fighting anyone.")
+		return
+	}
+
+	if len(s.word) == 0 {
+		if what := target(s.actor); what != nil {
+			s.Msg(s.actor, text.Info, "You are attacking ", what.As[TheName], ".")
+		}
+		return
+	}
+
+	where := s.actor.Ref[Where]
+	uids := Match(s.word, where)
+	uid := uids[0]
+	what := where.Who[uid]
+	if what == nil {
+		what = where.In[uid]
+	}
+
+	switch {
+	case what == nil:
+		s.Msg(s.actor, text.Bad, "You see no '", uid, "' here to target.")
+	case !opposed(s.actor, what):
+		s.Msg(s.actor, text.Bad, "You are not fighting ", what.As[TheName], ".")
+	case s.actor.Ref[Opponent] == what:
+		s.Msg(s.actor, text.Bad, "You are already attacking ", what.As[TheName], ".")
+	default:
+		s.actor.Ref[Opponent] = what
+		s.Msg(s.actor, text.Good, "You turn to attack ", what.As[TheName], "!")
+		s.Msg(what, text.Bad, s.actor.As[UTheName], " turns to attack you!")
+		s.Msg(where, text.Info, s.actor.As[UTheName], " turns to attack ", what.As[TheName], "!")
+	}
+}
+
+// engage starts the actor fighting what, which becomes the actor's current
+// target. If what is not attacking anyone it fights back against the actor.
+// Returns false, after notifying the actor, if the actor's location or what
+// vetoes combat.
+func (s *state) engage(what *Thing) bool {
+	where := s.actor.Ref[Where]
+
+	switch {
 	case where.As[VetoCombat] != "":
 		s.Msg(s.actor, text.Bad, where.As[VetoCombat])
+		return false
 	case what.As[VetoCombat] != "":
 		s.Msg(s.actor, text.Bad, what.As[VetoCombat])
-	default:
-		what.Any[Opponents] = append(what.Any[Opponents], s.actor.As[UID])
-		what.Suspend(Action)
+		return false
+	case s.actor.Is&Player == Player && what.Is&Player == Player && !s.pvp(what):
+		return false
+	}
 
-		s.actor.Any[Opponents] = append(s.actor.Any[Opponents], what.As[UID])
-		s.actor.Ref[Opponent] = what
-		s.actor.Int[CombatAfter] = roundDuration
-		s.actor.Schedule(Combat)
+	what.Any[Opponents] = append(what.Any[Opponents], s.actor.As[UID])
+	if what.Ref[Opponent] == nil {
+		what.Ref[Opponent] = s.actor
+	}
+	what.Suspend(Action)
+	what.Suspend(Wander)
 
-		s.Msg(s.actor, text.Good, "You attack ", what.As[TheName], "!")
-		s.Msg(what, text.Bad, s.actor.As[TheName], " attacks you!")
-		s.Msg(where, text.Info, s.actor.As[UTheName], " attacks ", what.As[TheName], "!")
-		s.Log("%s attacked %s (%s)", s.actor.As[Name], what.As[Name], what.As[UID])
+	s.actor.Any[Opponents] = append(s.actor.Any[Opponents], what.As[UID])
+	s.actor.Ref[Opponent] = what
+	s.actor.Suspend(Action)
+	s.actor.Suspend(Wander)
+
+	if where.Event[Combat] == nil {
+		where.Int[CombatAfter] = roundDuration
+		where.Schedule(Combat)
 	}
+	return true
 }
 
-func (s *state) Combat() {
+// rally has idle NPCs at who's location, with a FACTION matching who's
+// aliases, come to the aid of who. NPCs will not help against someone who is
+// also one of their faction.
+func (s *state) rally(who *Thing) {
+	foe := target(who)
+	if foe == nil {
+		return
+	}
+	for _, npc := range who.Ref[Where].In.Sort() {
+		if npc.Is&NPC == NPC && npc != who && npc != foe &&
+			len(npc.Any[Opponents]) == 0 && allied(npc, who) && !allied(npc, foe) {
+			s.subparseFor(npc, "ASSIST "+who.As[UID])
+		}
+	}
+}
 
-	what := s.actor.Ref[Opponent]
+// aid has the actor, if an NPC with a FACTION that is not already fighting,
+// come to the aid of an ally fighting at its location. Returns true if the
+// actor is now fighting, otherwise false.
+func (s *state) aid() bool {
+	if s.actor.Is&NPC != NPC || len(s.actor.Any[Faction]) == 0 || len(s.actor.Any[Opponents]) > 0 {
+		return false
+	}
 	where := s.actor.Ref[Where]
+	if where == nil || where.Is&Location != Location {
+		return false
+	}
 
-	if what == nil || where != what.Ref[Where] {
-		s.stopCombat(s.actor, nil)
-		s.Msg(s.actor, text.Info, "\nYou stop fighting, your opponent disappeared...")
+	for _, who := range append(where.Who.Sort(), where.In.Sort()...) {
+		if who == s.actor || !allied(s.actor, who) {
+			continue
+		}
+		if foe := target(who); foe != nil && foe != s.actor && !allied(s.actor, foe) {
+			s.subparse("ASSIST " + who.As[UID])
+			return len(s.actor.Any[Opponents]) > 0
+		}
+	}
+	return false
+}
+
+// allied returns true if who has an alias matching one of the NPC's FACTION
+// aliases, otherwise false.
+func allied(npc, who *Thing) bool {
+	return intersects(npc.Any[Faction], who.Any[Alias])
+}
+
+// opposed returns true if who is one of the actor's opponents, otherwise
+// false.
+func opposed(actor, who *Thing) bool {
+	for _, uid := range actor.Any[Opponents] {
+		if uid == who.As[UID] {
+			return true
+		}
+	}
+	return false
+}
+
+// target returns the opponent the actor is currently attacking. If the
+// current target is no longer at the actor's location the first opponent
+// that is becomes the current target. Returns nil if none of the actor's
+// opponents are at the actor's location.
+func target(actor *Thing) *Thing {
+	where := actor.Ref[Where]
+	if where == nil {
+		return nil
+	}
+	present := func(who *Thing) bool {
+		return who != nil && (where.Who[who.As[UID]] == who || where.In[who.As[UID]] == who)
+	}
+	if what := actor.Ref[Opponent]; present(what) {
+		return what
+	}
+	delete(actor.Ref, Opponent)
+	for _, uid := range actor.Any[Opponents] {
+		what := where.Who[uid]
+		if what == nil {
+			what = where.In[uid]
+		}
+		if present(what) {
+			actor.Ref[Opponent] = what
+			return what
+		}
+	}
+	return nil
+}
+
+// Combat resolves a round of combat at a location, it is run for the
+// location's Combat event. Everyone fighting at the location gets one attack
+// per round against their current target, in a random order each round. The
+// Combat event is rescheduled while there is anyone still fighting.
+func (s *state) Combat() {
+	where := s.actor
+	nextRound(where)
+
+	combatants := []*Thing{}
+	for _, who := range append(where.Who.Sort(), where.In.Sort()...) {
+		if len(who.Any[Opponents]) > 0 {
+			combatants = append(combatants, who)
+		}
+	}
+	rand.Shuffle(len(combatants), func(i, j int) {
+		combatants[i], combatants[j] = combatants[j], combatants[i]
+	})
+
+	fighting := false
+	for _, who := range combatants {
+
+		// Skip anyone killed, fled or no longer fighting earlier in the round
+		if where.Who[who.As[UID]] != who && where.In[who.As[UID]] != who {
+			continue
+		}
+		if len(who.Any[Opponents]) == 0 {
+			continue
+		}
+
+		// Each attack is delivered separately so that observers see everything
+		s2 := NewState(who)
+		s2.strike()
+		s2.mailman()
+
+		fighting = fighting || len(who.Any[Opponents]) > 0
+	}
+
+	if fighting {
+		where.Int[CombatAfter] = roundDuration
+		where.Schedule(Combat)
+		return
+	}
+	endFight(where)
+}
+
+// strike has the actor make one attack on their current target.
+func (s *state) strike() {
+
+	attacker := s.actor
+	where := attacker.Ref[Where]
+	defender := target(attacker)
+
+	if defender == nil {
+		s.stopCombat(attacker, nil)
+		s.Msg(attacker, text.Info, "\nYou stop fighting, your opponent disappeared...")
 		return
 	}
 
-	attacker, defender := s.actor, what
-	if roll := rand.Float64(); roll > s.hitChance(attacker, defender) {
-		attacker, defender = defender, attacker
+	chance := s.hitChance(attacker, defender)
+	if roll := rand.Float64(); roll > chance {
+		recordAttack(attacker, defender, chance, false, 0)
+		amsg, dmsg, omsg := Message(attacker, defender, "[%A] miss[/es] [%d].")
+		s.MsgAppend(attacker, text.Info, amsg)
+		s.MsgAppend(defender, text.Info, dmsg)
+		s.Msg(where, text.Info, omsg)
+		return
 	}
 
-	damage := damageFixed(attacker) + rand.Int63n(damageRandom(attacker)+1)
+	// Experience for killing defender is based on defender before damage
+	worth := attack(defender) + defense(defender)
+
+	damage := hitDamage(attacker, defender)
 	defender.Int[HealthCurrent] -= damage
+	recordAttack(attacker, defender, chance, true, damage)
 
 	amsg, dmsg, omsg := Message(attacker, defender, pickMessage(attacker))
 
@@ -143,31 +445,52 @@ func (s *state) Combat() {
 	s.MsgAppend(defender, text.Bad, dmsg)
 	s.Msg(where, text.Info, omsg)
 
-	// defender not killed, do health bookkeeping and go another round
+	s.wounded(attacker, defender, worth)
+}
+
+// wounded handles the aftermath of the attacker damaging the defender. If the
+// defender is still alive their health is restored over time and they may
+// panic and flee. If the defender was killed the attacker gains experience
+// for the kill, worth, and everyone stops fighting the defender. A corpse is
+// left for the defender, an NPC is junked and a player placed back into the
+// world at a starting location.
+func (s *state) wounded(attacker, defender *Thing, worth int64) {
+
+	where := attacker.Ref[Where]
+
+	// defender not killed, do health bookkeeping
 	if defender.Int[HealthCurrent] > 0 {
 		s.StatusUpdate(defender)
 		if defender.Event[Health] == nil {
 			defender.Schedule(Health)
 		}
-		s.actor.Int[CombatAfter] = roundDuration
-		s.actor.Schedule(Combat)
+
+		// If defender's health is below their WIMPY setting try and flee
+		if defender.Int[HealthCurrent] < defender.Int[Wimpy] {
+			s.Msg(defender, text.Bad, "You panic!")
+			s.subparseFor(defender, "FLEE")
+		}
 		return
 	}
+
 	s.Msg(attacker, "You kill ", defender.As[Name], "!")
 	s.Msg(defender, attacker.As[UTheName], " kills you!")
 	s.Msg(where, attacker.As[UTheName], " kills ", defender.As[Name], "!")
 
-	if s.actor.As[UID] == attacker.As[UID] {
-		s.Log("%s killed %s (%s)",
-			attacker.As[Name], defender.As[Name], defender.As[UID],
-		)
-	} else {
-		s.Log("%s killed by %s (%s)",
-			defender.As[Name], attacker.As[Name], attacker.As[UID],
-		)
+	s.experience(attacker, worth)
+
+	// Killer takes any coins a mobile was carrying. The mobile keeps its coins
+	// so that it has them again when it resets.
+	if defender.Is&NPC == NPC && defender.Int[Money] > 0 {
+		attacker.Int[Money] += defender.Int[Money]
+		s.Msg(attacker, text.Good, "You find ", coins(defender.Int[Money]), " on ", defender.As[TheName], ".")
 	}
 
-	// Stop everyone attacking defender and notify them, as they receive a
+	s.Log("%s killed %s (%s)",
+		attacker.As[Name], defender.As[Name], defender.As[UID],
+	)
+
+	// Stop everyone fighting defender and notify them, as they receive a
 	// specific message they won't get the message to the location.
 	for _, uid := range defender.Any[Opponents] {
 		who := where.Who[uid]
@@ -181,6 +504,7 @@ func (s *state) Combat() {
 		s.stopCombat(who, defender)
 	}
 	s.stopCombat(defender, nil)
+	debuff(defender)
 
 	// Create and place corpse
 	c := createCorpse(defender)
@@ -202,31 +526,286 @@ func (s *state) Combat() {
 	defender.Ref[Where] = start
 	start.Who[defender.As[UID]] = defender
 
-	if s.actor == defender {
-		s.subparse("$POOF")
-	} else {
-		s.subparseFor(defender, "$POOF")
-	}
+	s.subparseFor(defender, "$POOF")
 }
 
+// stopCombat stops who fighting what. If what is nil who stops fighting
+// everyone. Once who is not fighting anyone their Action and Wander events
+// are resumed and, if a player, they are given a summary of the fight.
 func (s *state) stopCombat(who, what *Thing) {
 	if who == nil {
 		return
 	}
-	if what == nil {
-		who.Cancel(Combat)
+	if what != nil {
+		who.Any[Opponents], _ = remainder(who.Any[Opponents], []string{what.As[UID]})
+		if who.Ref[Opponent] == what {
+			delete(who.Ref, Opponent)
+		}
+	}
+	if what == nil || len(who.Any[Opponents]) == 0 {
 		who.Schedule(Action)
+		who.Schedule(Wander)
 		delete(who.Ref, Opponent)
 		delete(who.Any, Opponents)
-	} else {
-		who.Any[Opponents], _ = remainder(who.Any[Opponents], []string{what.As[UID]})
-		if len(who.Any[Opponents]) == 0 {
-			who.Schedule(Action)
-			who.Cancel(Combat)
-			delete(who.Ref, Opponent)
-			delete(who.Any, Opponents)
+		s.fightSummary(who)
+	}
+}
+
+// Flee tries to escape from a fight through a random exit. Exits that are
+// blocked, or hidden from the actor, are not used. The chance of escaping is
+// the actor's chance of hitting their opponent. If the actor escapes they stop
+// fighting and their opponents stop fighting them.
+func (s *state) Flee() {
+
+	if len(s.actor.Any[Opponents]) == 0 {
+		s.Msg(s.actor, text.Info, "You are not fighting anyone, no need to flee.")
+		return
+	}
+
+	where := s.actor.Ref[Where]
+	dirs := []refKey{}
+	for dir := North; dir <= Down; dir++ {
+		if where.Ref[dir] != nil && !hiddenExit(s.actor, where, dir) && s.blocked(dir) == "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		s.Msg(s.actor, text.Bad, "You look for a way to flee, but there is nowhere to run!")
+		return
+	}
+
+	if overloaded(s.actor) {
+		s.Msg(s.actor, text.Bad, "You try to flee but are carrying too much to move!")
+		return
+	}
+
+	if winded(s.actor) {
+		s.Msg(s.actor, text.Bad, "You try to flee but are too out of breath from carrying your load!")
+		return
+	}
+
+	opponents := []*Thing{}
+	for _, uid := range s.actor.Any[Opponents] {
+		who := where.Who[uid]
+		if who == nil {
+			who = where.In[uid]
+		}
+		if who != nil {
+			opponents = append(opponents, who)
+		}
+	}
+
+	notify := len(where.Who) < cfg.crowdSize
+
+	what := s.actor.Ref[Opponent]
+	if what == nil && len(opponents) > 0 {
+		what = opponents[0]
+	}
+	// Being burdened makes it harder to get away
+	chance := 0.0
+	if what != nil {
+		chance = s.hitChance(s.actor, what)
+		if burdened(s.actor) {
+			chance /= 2
+		}
+	}
+
+	if what != nil && rand.Float64() > chance {
+		s.Msg(s.actor, text.Bad, "You try to flee but ", what.As[TheName], " stops you!")
+		s.Msg(what, text.Good, s.actor.As[UTheName], " tries to flee but you stop them!")
+		if notify {
+			s.Msg(where, text.Info, s.actor.As[UTheName], " tries to flee but ", what.As[TheName], " stops them!")
+		}
+		return
+	}
+
+	dir := dirs[rand.Intn(len(dirs))]
+	for _, who := range opponents {
+		s.stopCombat(who, s.actor)
+		s.Msg(who, text.Info, s.actor.As[UTheName], " flees ", DirToName[dir], "!")
+	}
+	s.stopCombat(s.actor, nil)
+
+	// Bystanders see the leave message when the actor moves
+	s.Msg(s.actor, text.Good, "You flee ", DirToName[dir], "!")
+	s.Log("%s fled from combat", s.actor.As[Name])
+	s.subparse(DirToName[dir])
+}
+
+// Wimpy shows or sets the health below which the actor automatically tries
+// to flee from a fight. Setting WIMPY to zero or OFF turns it off.
+func (s *state) Wimpy() {
+
+	if len(s.word) == 0 {
+		if s.actor.Int[Wimpy] == 0 {
+			s.Msg(s.actor, text.Info, "WIMPY is off, you will fight to the bitter end.")
+		} else {
+			s.Msg(s.actor, text.Info, "You will try to flee when your health drops below ", strconv.FormatInt(s.actor.Int[Wimpy], 10), ".")
+		}
+		return
+	}
+
+	wimpy, err := strconv.ParseInt(s.word[0], 10, 64)
+	if s.word[0] == "OFF" {
+		wimpy, err = 0, nil
+	}
+
+	switch {
+	case err != nil || wimpy < 0:
+		s.Msg(s.actor, text.Bad, "WIMPY needs a health value or OFF, for example: WIMPY 5")
+	case wimpy > s.actor.Int[HealthMaximum]:
+		s.Msg(s.actor, text.Bad, "You can't set WIMPY higher than your maximum health of ", strconv.FormatInt(s.actor.Int[HealthMaximum], 10), ".")
+	case wimpy == 0:
+		delete(s.actor.Int, Wimpy)
+		s.Msg(s.actor, text.Good, "WIMPY is now off, you will fight to the bitter end.")
+	default:
+		s.actor.Int[Wimpy] = wimpy
+		s.Msg(s.actor, text.Good, "You will now try to flee when your health drops below ", strconv.FormatInt(wimpy, 10), ".")
+	}
+}
+
+// PvP shows or sets whether the actor has PvP turned on. Once PvP is turned
+// on or off it cannot be changed again until the configured cool-down period
+// has passed.
+func (s *state) PvP() {
+
+	if len(s.word) == 0 {
+		if s.actor.Is&PvP == PvP {
+			s.Msg(s.actor, text.Info, "PVP is on, other players can fight you.")
+		} else {
+			s.Msg(s.actor, text.Info, "PVP is off, other players cannot fight you.")
+		}
+		switch policy(s.actor.Ref[Where]) {
+		case "FORBIDDEN":
+			s.Msg(s.actor, text.Info, "Fighting other players is forbidden here.")
+		case "FREE":
+			s.Msg(s.actor, text.Info, "Anyone can fight anyone here, PVP on or not.")
+		}
+		return
+	}
+
+	var on bool
+	switch s.word[0] {
+	case "ON":
+		on = true
+	case "OFF":
+		on = false
+	default:
+		s.Msg(s.actor, text.Bad, "PVP needs ON or OFF, for example: PVP ON")
+		return
+	}
+
+	wait := time.Duration(s.actor.Int[PvPChanged] + cfg.pvpCooldown - time.Now().UnixNano())
+
+	switch {
+	case on == (s.actor.Is&PvP == PvP):
+		s.Msg(s.actor, text.Info, "PVP is already ", s.word[0], ".")
+	case len(s.actor.Any[Opponents]) > 0:
+		s.Msg(s.actor, text.Bad, "You can't change PVP while fighting!")
+	case wait > 0:
+		s.Msg(s.actor, text.Bad, "You must wait another ", wait.Round(time.Second).String(), " before changing PVP again.")
+	case on:
+		s.actor.Is |= PvP
+		s.actor.Int[PvPChanged] = time.Now().UnixNano()
+		s.Msg(s.actor, text.Good, "PVP is now on, other players can fight you.")
+		s.Log("%s turned PvP on", s.actor.As[Name])
+	default:
+		s.actor.Is &^= PvP
+		s.actor.Int[PvPChanged] = time.Now().UnixNano()
+		s.Msg(s.actor, text.Good, "PVP is now off, other players cannot fight you.")
+		s.Log("%s turned PvP off", s.actor.As[Name])
+	}
+}
+
+// pvp returns true if the actor, a player, can fight the passed player at the
+// actor's location, otherwise the actor is told why not and false returned.
+func (s *state) pvp(what *Thing) bool {
+	switch policy(s.actor.Ref[Where]) {
+	case "FREE":
+		return true
+	case "FORBIDDEN":
+		s.Msg(s.actor, text.Bad, "You cannot fight other players here.")
+		return false
+	}
+
+	switch {
+	case s.actor.Is&PvP != PvP:
+		s.Msg(s.actor, text.Bad, "You need to turn PVP on to fight other players.")
+	case what.Is&PvP != PvP:
+		s.Msg(s.actor, text.Bad, what.As[UTheName], " does not have PVP turned on.")
+	default:
+		return true
+	}
+	return false
+}
+
+// pvpPolicies are the valid PvP policies, see ValidPvPPolicy.
+var pvpPolicies = []string{"FORBIDDEN", "CONSENSUAL", "FREE"}
+
+// ValidPvPPolicy returns true if policy is a known PvP policy, FORBIDDEN,
+// CONSENSUAL or FREE, otherwise false.
+func ValidPvPPolicy(policy string) bool {
+	return intersects([]string{policy}, pvpPolicies)
+}
+
+// policy returns the PvP policy for the passed location, FORBIDDEN,
+// CONSENSUAL or FREE. If the location does not have a policy of its own the
+// configured default policy is returned.
+func policy(where *Thing) string {
+	if p := where.As[PvPPolicy]; p != "" {
+		return p
+	}
+	return cfg.pvpPolicy
+}
+
+// pvpMark returns a marker to display after a player's name if the player
+// has PvP turned on, otherwise an empty string.
+func pvpMark(who *Thing) string {
+	if who.Is&PvP == PvP {
+		return " [PvP]"
+	}
+	return ""
+}
+
+// aggress has the actor, if aggressive and not already fighting, attack a
+// random player at its location that it is hostile towards. Returns true if
+// an attack was started, otherwise false. The attack is made using ATTACK so
+// that crowd and VetoCombat rules are respected.
+func (s *state) aggress() bool {
+	if s.actor.Is&Aggressive != Aggressive || len(s.actor.Any[Opponents]) > 0 {
+		return false
+	}
+	where := s.actor.Ref[Where]
+	if where == nil || where.Is&Location != Location {
+		return false
+	}
+
+	targets := []*Thing{}
+	for _, who := range where.Who.Sort() {
+		if hostile(s.actor, who) {
+			targets = append(targets, who)
 		}
 	}
+	if len(targets) == 0 {
+		return false
+	}
+
+	s.subparse("ATTACK " + targets[rand.Intn(len(targets))].As[UID])
+	return len(s.actor.Any[Opponents]) > 0
+}
+
+// hostile returns true if the aggressive NPC would attack who, otherwise
+// false. Only players are attacked. The NPC's ALLOW and DENY aliases are
+// checked in the same way as for a barrier, with players allowed by the
+// barrier not being attacked. As for barriers a player's name alias is not
+// checked.
+func hostile(npc, who *Thing) bool {
+	if who.Is&Player != Player {
+		return false
+	}
+	aliases, _ := remainder(who.Any[Alias], []string{strings.ToUpper(who.As[Name])})
+	a, d := npc.Any[AggressiveAllow], npc.Any[AggressiveDeny]
+	return !intersects(a, aliases) && (d == nil || intersects(d, aliases))
 }
 
 var (
@@ -286,23 +865,31 @@ func attack(actor *Thing) int64 {
 // defense returns the total defense value for an actor. Items can provide
 // positive or negative contributions. Minimum defense is 1.0.
 func defense(actor *Thing) int64 {
-	d := actor.Int[Armour] + actor.Int[HealthCurrent]
-	for _, item := range actor.In {
-		if item.Is&(Wearing|Wielding) != 0 {
-			d += item.Int[Armour]
-		}
-	}
+	d := armour(actor) + actor.Int[HealthCurrent]
 	if d < 1 {
 		d = 1
 	}
 	return d
 }
 
+// armour returns the total armour for an actor. This includes natural, buffed
+// and wielded/worn item armour. Items can provide positive or negative
+// contributions.
+func armour(actor *Thing) int64 {
+	a := actor.Int[Armour] + actor.Int[BuffArmour]
+	for _, item := range actor.In {
+		if item.Is&(Wearing|Wielding) != 0 {
+			a += item.Int[Armour]
+		}
+	}
+	return a
+}
+
 // damageFixed returns the total amount of fixed damage an actor can cause.
-// This includes natural and wielded/worn item damage. Items can provide
+// This includes natural, buffed and wielded/worn item damage. Items can provide
 // positive or negative contributions. Minimum damage is 1.
 func damageFixed(actor *Thing) int64 {
-	df := actor.Int[DamageFixed]
+	df := actor.Int[DamageFixed] + actor.Int[BuffDamageFixed]
 	for _, item := range actor.In {
 		if item.Is&(Wearing|Wielding) != 0 {
 			df += item.Int[DamageFixed]
@@ -314,11 +901,90 @@ func damageFixed(actor *Thing) int64 {
 	return df
 }
 
+// hitDamage returns the damage inflicted by a successful attack of the
+// attacker on the defender. The attacker's natural and buffed damage and the
+// damage of each wielded/worn item is totalled by damage type. The damage for
+// each type is then reduced, or increased, by the defender's resistance to
+// that type. Damage without a type is not resisted. Minimum damage is 1,
+// unless reduced by resistances when minimum damage is 0.
+func hitDamage(attacker, defender *Thing) int64 {
+	fixed, random := map[string]int64{}, map[string]int64{}
+	add := func(t *Thing) {
+		fixed[t.As[DamageType]] += t.Int[DamageFixed]
+		random[t.As[DamageType]] += t.Int[DamageRandom]
+	}
+	add(attacker)
+	fixed[attacker.As[DamageType]] += attacker.Int[BuffDamageFixed]
+	random[attacker.As[DamageType]] += attacker.Int[BuffDamageRandom]
+	for _, item := range attacker.In {
+		if item.Is&(Wearing|Wielding) != 0 {
+			add(item)
+		}
+	}
+
+	var total, resisted int64
+	for dt, d := range fixed {
+		if random[dt] > 0 {
+			d += rand.Int63n(random[dt] + 1)
+		}
+		if d < 1 {
+			continue
+		}
+		total += d
+		resisted += d - d*resistance(defender, dt)/100
+	}
+
+	if total < 1 {
+		return 1
+	}
+	return resisted
+}
+
+// resistance returns the total resistance of an actor to the passed damage
+// type, as a percentage. This includes natural and wielded/worn item
+// resistances. Items can provide positive or negative contributions. Maximum
+// resistance is 100, immune to the damage type. Negative resistances are
+// vulnerabilities, increasing the damage taken.
+func resistance(actor *Thing, dt string) int64 {
+	if dt == "" {
+		return 0
+	}
+	sum := func(t *Thing) (r int64) {
+		for _, res := range t.Any[Resistance] {
+			if rdt, pct := splitResistance(res); rdt == dt {
+				r += pct
+			}
+		}
+		return r
+	}
+	r := sum(actor)
+	for _, item := range actor.In {
+		if item.Is&(Wearing|Wielding) != 0 {
+			r += sum(item)
+		}
+	}
+	if r > 100 {
+		r = 100
+	}
+	return r
+}
+
+// splitResistance splits a resistance, as stored in Thing.Any[Resistance],
+// into its damage type and percentage.
+func splitResistance(res string) (string, int64) {
+	parts := strings.SplitN(res, "→", 2)
+	if len(parts) != 2 {
+		return parts[0], 0
+	}
+	pct, _ := strconv.ParseInt(parts[1], 10, 64)
+	return parts[0], pct
+}
+
 // damageRandom returns the total amount of random damage an actor can cause.
-// This includes naturnal and wielded/worn item damage. Items can provide
-// positive or negative contributions. Minimum damage is 0.
+// This includes naturnal, buffed and wielded/worn item damage. Items can
+// provide positive or negative contributions. Minimum damage is 0.
 func damageRandom(actor *Thing) int64 {
-	dr := actor.Int[DamageRandom]
+	dr := actor.Int[DamageRandom] + actor.Int[BuffDamageRandom]
 	for _, item := range actor.In {
 		if item.Is&(Wearing|Wielding) != 0 {
 			dr += item.Int[DamageRandom]
@@ -0,0 +1,203 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.wolfmud.org/WolfMUD.git/text"
+)
+
+// fight records the fight taking place at a location. Fights are numbered
+// uniquely while the server is running.
+type fight struct {
+	id    uint64
+	round int
+}
+
+// tally records what happened to a participant during a fight, used for the
+// summary given to players when they stop fighting.
+type tally struct {
+	hits   int   // Number of attacks that hit
+	misses int   // Number of attacks that missed
+	dealt  int64 // Total damage dealt
+	struck int   // Number of times hit
+	taken  int64 // Total damage taken
+	kills  int   // Number of opponents killed
+	killed bool  // True if killed during the fight
+}
+
+// combatEntry is a single line, one attack, written to the combat log.
+type combatEntry struct {
+	Time     time.Time `json:"time"`
+	Fight    uint64    `json:"fight"`
+	Round    int       `json:"round"`
+	Location string    `json:"location,omitempty"`
+	Attacker string    `json:"attacker"`
+	Defender string    `json:"defender"`
+	Weapons  []string  `json:"weapons,omitempty"`
+	Chance   float64   `json:"chance"`
+	Hit      bool      `json:"hit"`
+	Damage   int64     `json:"damage"`
+	Health   int64     `json:"health"`
+	Killed   bool      `json:"killed"`
+}
+
+// Fights in progress keyed by location, tallies for players and NPCs
+// currently fighting and the combat log. All protected by the BWL.
+var (
+	fights    = map[*Thing]*fight{}
+	tallies   = map[*Thing]*tally{}
+	fightID   uint64
+	combatLog *json.Encoder
+)
+
+// nextRound advances the fight at the passed location to the next round,
+// starting a new fight if there is not one already in progress.
+func nextRound(where *Thing) {
+	f := fights[where]
+	if f == nil {
+		fightID++
+		f = &fight{id: fightID}
+		fights[where] = f
+	}
+	f.round++
+}
+
+// endFight records that the fight at the passed location is over.
+func endFight(where *Thing) {
+	delete(fights, where)
+}
+
+// forgetCombat discards any fight or tally recorded for the passed Thing. It
+// is called when a Thing is junked or freed, for example when a player quits
+// in the middle of a fight, so that entries are not left behind.
+func forgetCombat(t *Thing) {
+	delete(fights, t)
+	delete(tallies, t)
+}
+
+// tallyFor returns the tally for who, creating a new tally if who does not
+// have one.
+func tallyFor(who *Thing) *tally {
+	t := tallies[who]
+	if t == nil {
+		t = &tally{}
+		tallies[who] = t
+	}
+	return t
+}
+
+// recordAttack updates the attacker's and defender's tallies for an attack
+// and writes the attack to the combat log, if enabled.
+func recordAttack(attacker, defender *Thing, chance float64, hit bool, damage int64) {
+	at, dt := tallyFor(attacker), tallyFor(defender)
+	killed := hit && defender.Int[HealthCurrent] <= 0
+	switch {
+	case hit:
+		at.hits++
+		at.dealt += damage
+		dt.struck++
+		dt.taken += damage
+	default:
+		at.misses++
+	}
+	if killed {
+		at.kills++
+		dt.killed = true
+	}
+
+	if cfg.combatLog == "" {
+		return
+	}
+
+	where := attacker.Ref[Where]
+	entry := combatEntry{
+		Time:     time.Now(),
+		Attacker: attacker.As[Name],
+		Defender: defender.As[Name],
+		Chance:   chance,
+		Hit:      hit,
+		Damage:   damage,
+		Health:   defender.Int[HealthCurrent],
+		Killed:   killed,
+	}
+	if where != nil {
+		entry.Location = where.As[Path]
+		if f := fights[where]; f != nil {
+			entry.Fight, entry.Round = f.id, f.round
+		}
+	}
+	for _, item := range attacker.In.Sort() {
+		if item.Is&Wielding == Wielding {
+			entry.Weapons = append(entry.Weapons, item.As[Name])
+		}
+	}
+
+	if combatLog == nil {
+		f, err := os.OpenFile(cfg.combatLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0660)
+		if err != nil {
+			log.Printf("Combat log disabled: %s", err)
+			combatLog = json.NewEncoder(io.Discard)
+			return
+		}
+		log.Printf("Writing combat log: %s", cfg.combatLog)
+		combatLog = json.NewEncoder(f)
+	}
+	if err := combatLog.Encode(entry); err != nil {
+		log.Printf("Error writing combat log: %s", err)
+	}
+}
+
+// fightSummary sends a summary of the fight to who, if a player, and discards
+// who's tally.
+func (s *state) fightSummary(who *Thing) {
+	t := tallies[who]
+	delete(tallies, who)
+	if t == nil || who.Is&Player != Player {
+		return
+	}
+
+	plural := func(n int, one, many string) string {
+		if n == 1 {
+			return one
+		}
+		return strconv.Itoa(n) + " " + many
+	}
+
+	parts := []string{}
+	if attacks := t.hits + t.misses; attacks > 0 {
+		parts = append(parts, "you hit with "+strconv.Itoa(t.hits)+" of "+
+			plural(attacks, "1 attack", "attacks")+" for "+
+			strconv.FormatInt(t.dealt, 10)+" damage")
+	}
+	if t.struck > 0 {
+		parts = append(parts, "you were hit "+plural(t.struck, "once", "times")+
+			" for "+strconv.FormatInt(t.taken, 10)+" damage")
+	} else {
+		parts = append(parts, "you were not hit")
+	}
+	if t.kills > 0 {
+		parts = append(parts, "you killed "+plural(t.kills, "1 opponent", "opponents"))
+	}
+	if t.killed {
+		parts = append(parts, "you were killed")
+	}
+
+	summary := strings.Join(parts[:len(parts)-1], ", ")
+	if summary != "" {
+		summary += " and "
+	}
+	summary += parts[len(parts)-1]
+
+	s.Msg(who, text.Info, "Fight summary: ", summary, ".")
+}
@@ -17,6 +17,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"code.wolfmud.org/WolfMUD.git/recordjar"
 	"code.wolfmud.org/WolfMUD.git/recordjar/encode"
@@ -68,6 +69,7 @@ func RegisterCommandHandlers() {
 		"NORTHWEST": (*state).Move,
 		"EXAM":      (*state).Examine,
 		"EXAMINE":   (*state).Examine,
+		"SEARCH":    (*state).Search,
 		"INV":       (*state).Inventory,
 		"INVENTORY": (*state).Inventory,
 		"DROP":      (*state).Drop,
@@ -77,6 +79,8 @@ func RegisterCommandHandlers() {
 		"READ":      (*state).Read,
 		"OPEN":      (*state).Open,
 		"CLOSE":     (*state).Close,
+		"LOCK":      (*state).LockItem,
+		"UNLOCK":    (*state).UnlockItem,
 		"COMMANDS":  (*state).Commands,
 		"\"":        (*state).Say,
 		"SAY":       (*state).Say,
@@ -95,6 +99,26 @@ func RegisterCommandHandlers() {
 		"WHISPER":   (*state).Whisper,
 		"ATTACK":    (*state).Attack,
 		"KILL":      (*state).Attack,
+		"ASSIST":    (*state).Assist,
+		"TARGET":    (*state).Target,
+		"PVP":       (*state).PvP,
+		"FLEE":      (*state).Flee,
+		"WIMPY":     (*state).Wimpy,
+		"SCORE":     (*state).Score,
+		"LIST":      (*state).List,
+		"BUY":       (*state).Buy,
+		"SELL":      (*state).Sell,
+		"VALUE":     (*state).Value,
+		"EAT":       (*state).Eat,
+		"DRINK":     (*state).Drink,
+		"FILL":      (*state).Fill,
+		"CAST":      (*state).Cast,
+		"USE":       (*state).Cast,
+		"ABILITIES": (*state).Abilities,
+
+		// Light sources
+		"LIGHT":      (*state).LightItem,
+		"EXTINGUISH": (*state).Extinguish,
 
 		// Out of character commands
 		"/WHO":     (*state).Who,
@@ -111,24 +135,39 @@ func RegisterCommandHandlers() {
 		"#EVAL":     (*state).Eval,
 
 		// Scripting only commands
-		"$POOF":    (*state).Poof,
-		"$ACT":     (*state).Act,
-		"$ACTION":  (*state).Action,
-		"$RESET":   (*state).Reset,
-		"$CLEANUP": (*state).Cleanup,
-		"$TRIGGER": (*state).Trigger,
-		"$QUIT":    (*state).Quit,
-		"$HEALTH":  (*state).Health,
-		"$COMBAT":  (*state).Combat,
+		"$POOF":     (*state).Poof,
+		"$ACT":      (*state).Act,
+		"$ACTION":   (*state).Action,
+		"$RESET":    (*state).Reset,
+		"$CLEANUP":  (*state).Cleanup,
+		"$TRIGGER":  (*state).Trigger,
+		"$QUIT":     (*state).Quit,
+		"$HEALTH":   (*state).Health,
+		"$HUNGER":   (*state).Hunger,
+		"$MANA":     (*state).Mana,
+		"$BUFF":     (*state).Buff,
+		"$COOLDOWN": (*state).Cooldown,
+		"$COMBAT":   (*state).Combat,
+		"$BURN":     (*state).Burn,
+		"$ECHO":     (*state).Echo,
+		"$FORGET":   (*state).Forget,
+		"$WANDER":   (*state).Wander,
 	}
 
 	eventCommands = map[eventKey]string{
-		Action:  "$ACTION",
-		Reset:   "$RESET",
-		Cleanup: "$CLEANUP",
-		Trigger: "$TRIGGER",
-		Health:  "$HEALTH",
-		Combat:  "$COMBAT",
+		Action:   "$ACTION",
+		Reset:    "$RESET",
+		Cleanup:  "$CLEANUP",
+		Trigger:  "$TRIGGER",
+		Health:   "$HEALTH",
+		Hunger:   "$HUNGER",
+		Mana:     "$MANA",
+		Buff:     "$BUFF",
+		Cooldown: "$COOLDOWN",
+		Combat:   "$COMBAT",
+		Burn:     "$BURN",
+		Forget:   "$FORGET",
+		Wander:   "$WANDER",
 	}
 
 	// precompute a sorted list of available player and admin commands. Scripting
@@ -217,8 +256,20 @@ func (s *state) Look() {
 		s.Msg(s.actor, text.Cyan, "[The Void]\n", text.Reset,
 			"You are in a dark void. Around you nothing.",
 			"No stars, no light, no heat and no sound.")
-	case where.Is&Dark == Dark:
+	case !lit(where):
 		s.Msg(s.actor, text.Bad, "It's too dark to see anything!")
+
+		// Even in the dark exits can still be found by feel
+		for dir, found := North, false; dir <= Down; dir++ {
+			switch {
+			case where.Ref[dir] == nil, hiddenExit(s.actor, where, dir):
+			case !found:
+				s.Msg(s.actor, text.Cyan, "You can feel exits: ", text.Reset, DirToName[dir])
+				found = true
+			default:
+				s.MsgAppend(s.actor, ", ", DirToName[dir])
+			}
+		}
 	default:
 		s.Msg(s.actor, text.Cyan, "[", where.As[Name], "]", text.Reset)
 		s.Msg(s.actor, where.As[Description], "\n")
@@ -228,14 +279,14 @@ func (s *state) Look() {
 				if who == s.actor {
 					continue
 				}
-				s.Msg(s.actor, text.Green, "You see ", who.As[Name], " here")
+				s.Msg(s.actor, text.Green, "You see ", who.As[Name], pvpMark(who), " here")
 				if who.Ref[Opponent] != nil {
 					s.MsgAppend(s.actor, " attacking ", who.Ref[Opponent].As[TheName])
 				}
 				s.MsgAppend(s.actor, ".")
 			}
 			for _, item := range where.In.Sort() {
-				if item.Is&Narrative == Narrative || item == s.actor {
+				if item.Is&Narrative == Narrative || item == s.actor || hiddenItem(s.actor, item) {
 					continue
 				}
 				s.Msg(s.actor, text.Yellow, "You see ", item.As[Name], " here")
@@ -255,7 +306,7 @@ func (s *state) Look() {
 
 		// Get directions in a fixed order
 		for dir := North; dir <= Down; dir++ {
-			if where.Ref[dir] != nil {
+			if where.Ref[dir] != nil && !hiddenExit(s.actor, where, dir) {
 				if s.buf[s.actor].Len() == mark {
 					s.Msg(s.actor, text.Cyan, "You see exits: ", text.Reset, DirToName[dir])
 				} else {
@@ -281,7 +332,7 @@ func (s *state) Move() {
 	dir := NameToDir[s.cmd]
 	where := s.actor.Ref[Where]
 
-	if where.Ref[dir] == nil {
+	if where.Ref[dir] == nil || hiddenExit(s.actor, where, dir) {
 		s.Msg(s.actor, text.Bad, "You can't go ", DirToName[dir], ".")
 		return
 	}
@@ -291,6 +342,80 @@ func (s *state) Move() {
 		return
 	}
 
+	if overloaded(s.actor) {
+		s.Msg(s.actor, text.Bad, "You are carrying too much to move.")
+		return
+	}
+
+	if winded(s.actor) {
+		s.Msg(s.actor, text.Bad, "You are carrying a heavy load and need to catch your breath before moving on.")
+		return
+	}
+
+	if msg := s.blocked(dir); msg != "" {
+		s.Msg(s.actor, text.Bad, msg)
+		return
+	}
+
+	switch {
+	case where.Ref[dir] == nil:
+		s.Msg(s.actor, text.Bad, "Oops! You can't actually go ", DirToName[dir], ".")
+	case s.actor.Is&Player != Player:
+		from := where
+		s.actor.Int[Moved] = time.Now().UnixNano()
+		delete(where.In, s.actor.As[UID])
+		if len(where.Who) < cfg.crowdSize {
+			s.MsgAppend(where, text.Info, s.exitMessage(from, ExitLeave, dir,
+				s.actor.As[UTheName]+" leaves "+DirToName[dir]+"."))
+		}
+
+		where = where.Ref[dir]
+		s.actor.Ref[Where] = where
+		where.In[s.actor.As[UID]] = s.actor
+		if len(where.Who) < cfg.crowdSize {
+			s.MsgAppend(where, text.Info, s.exitMessage(from, ExitArrive, dir,
+				s.actor.As[UName]+" enters."))
+		}
+		if !s.aggress() {
+			s.aid()
+		}
+	default:
+		from := where
+		s.actor.Int[Moved] = time.Now().UnixNano()
+		s.hooks(OnLeave, append([]*Thing{where}, where.In.Sort()...)...)
+		delete(where.Who, s.actor.As[UID])
+		if len(where.Who) < cfg.crowdSize {
+			s.MsgAppend(where, text.Info, s.exitMessage(from, ExitLeave, dir,
+				s.actor.As[UTheName]+" leaves "+DirToName[dir]+"."))
+		}
+
+		where = where.Ref[dir]
+		s.actor.Ref[Where] = where
+		where.Who[s.actor.As[UID]] = s.actor
+		if len(where.Who) < cfg.crowdSize {
+			s.MsgAppend(where, text.Info, s.exitMessage(from, ExitArrive, dir,
+				s.actor.As[UName]+" enters."))
+		}
+		if msg := s.exitMessage(from, ExitTraverse, dir, ""); msg != "" {
+			s.Msg(s.actor, text.Info, msg)
+		}
+		s.Look()
+		s.hooks(OnEnter, append([]*Thing{where}, where.In.Sort()...)...)
+		for _, npc := range where.In.Sort() {
+			if npc.Is&Aggressive == Aggressive && len(npc.Any[Opponents]) == 0 && hostile(npc, s.actor) {
+				s.subparseFor(npc, "ATTACK "+s.actor.As[UID])
+			}
+		}
+	}
+}
+
+// blocked returns a message saying why the actor cannot go in the given
+// direction from their current location, because of a barrier or blocker. If
+// the actor is not blocked an empty string is returned.
+func (s *state) blocked(dir refKey) string {
+
+	where := s.actor.Ref[Where]
+
 	// Alias is a helper closure that calculates the aliases for the actor when
 	// first called. Subsequent calls return the calculated value. For players
 	// we don't test barriers against the player's name alias. Otherwise some
@@ -313,8 +438,7 @@ func (s *state) Move() {
 	if NameToDir[where.As[Barrier]] == dir {
 		a, d := where.Any[BarrierAllow], where.Any[BarrierDeny]
 		if !intersects(a, aliases()) && (d == nil || intersects(d, aliases())) {
-			s.Msg(s.actor, text.Bad, "You can't go ", DirToName[dir], ", something is blocking your way.")
-			return
+			return "You can't go " + DirToName[dir] + ", something is blocking your way."
 		}
 	}
 
@@ -325,13 +449,11 @@ func (s *state) Move() {
 		}
 		a, d := item.Any[BarrierAllow], item.Any[BarrierDeny]
 		if !intersects(a, aliases()) && (d == nil || intersects(d, aliases())) {
-			s.Msg(s.actor, text.Bad, "You can't go ", DirToName[dir], ", ", or(item.As[TheName], "something"), " is blocking your way.")
-			return
+			return "You can't go " + DirToName[dir] + ", " + or(item.As[TheName], "something") + " is blocking your way."
 		}
 	}
 
 	// Try and find first blocker for direction we want to go
-	var blocker *Thing
 	for _, item := range where.In {
 		if item.As[Blocker] == "" {
 			continue
@@ -342,43 +464,27 @@ func (s *state) Move() {
 			blocking = ReverseDir[blocking]
 		}
 		if blocking == dir && item.Is&Open != Open {
-			blocker = item
-			break
+			return "You can't go " + DirToName[dir] + ", " + item.As[TheName] + " is blocking your way."
 		}
 	}
 
-	switch {
-	case blocker != nil:
-		s.Msg(s.actor, text.Bad, "You can't go ", DirToName[dir], ", ",
-			blocker.As[TheName], " is blocking your way.")
-	case where.Ref[dir] == nil:
-		s.Msg(s.actor, text.Bad, "Oops! You can't actually go ", DirToName[dir], ".")
-	case s.actor.Is&Player != Player:
-		delete(where.In, s.actor.As[UID])
-		if len(where.Who) < cfg.crowdSize {
-			s.MsgAppend(where, text.Info, s.actor.As[UTheName], " leaves ", DirToName[dir], ".")
-		}
-
-		where = where.Ref[dir]
-		s.actor.Ref[Where] = where
-		where.In[s.actor.As[UID]] = s.actor
-		if len(where.Who) < cfg.crowdSize {
-			s.MsgAppend(where, text.Info, s.actor.As[UName], " enters.")
-		}
-	default:
-		delete(where.Who, s.actor.As[UID])
-		if len(where.Who) < cfg.crowdSize {
-			s.MsgAppend(where, text.Info, s.actor.As[UTheName], " leaves ", DirToName[dir], ".")
-		}
+	return ""
+}
 
-		where = where.Ref[dir]
-		s.actor.Ref[Where] = where
-		where.Who[s.actor.As[UID]] = s.actor
-		if len(where.Who) < cfg.crowdSize {
-			s.MsgAppend(where, text.Info, s.actor.As[UName], " enters.")
-		}
-		s.Look()
+// exitMessage returns the custom message of the given type for the exit in the
+// given direction from the passed location. The message is returned as seen by
+// the actor for ExitTraverse messages and as seen by observers otherwise, see
+// Message for details. If there is no custom message def is returned.
+func (s *state) exitMessage(from *Thing, key anyKey, dir refKey, def string) string {
+	msgs := from.Any[key]
+	if int(dir-North) >= len(msgs) || msgs[dir-North] == "" {
+		return def
+	}
+	a, _, o := Message(s.actor, from, msgs[dir-North])
+	if key == ExitTraverse {
+		return a
 	}
+	return o
 }
 
 // FIXME(diddymus): At the moment containers can contain narritives. This
@@ -402,6 +508,8 @@ func (s *state) Examine() {
 	}
 
 	switch {
+	case what == nil && !lit(s.actor.Ref[Where]):
+		s.Msg(s.actor, text.Bad, "It's too dark to see any '", uid, "' to examine.")
 	case what == nil:
 		s.Msg(s.actor, text.Bad, "You see no '", uid, "' to examine.")
 	case len(uids) > 1:
@@ -416,10 +524,24 @@ func (s *state) Examine() {
 		case what.As[Blocker] == "":
 		case what.Is&Open == Open:
 			s.MsgAppend(s.actor, " It is open.")
+		case what.Is&Locked == Locked:
+			s.MsgAppend(s.actor, " It is closed and locked.")
 		default:
 			s.MsgAppend(s.actor, " It is closed.")
 		}
 
+		// If a drink container, how full is it?
+		switch {
+		case what.Int[SipsMaximum] == 0:
+			// Not a drink container
+		case what.Int[Sips] == 0:
+			s.MsgAppend(s.actor, " It is empty.")
+		case what.Int[Sips] == what.Int[SipsMaximum]:
+			s.MsgAppend(s.actor, " It is full.")
+		default:
+			s.MsgAppend(s.actor, " It is partly full.")
+		}
+
 		// If a container then count non-narrative items in it. When examining
 		// containers we only want to describe non-narrative content.
 		itemCount := 0
@@ -435,6 +557,8 @@ func (s *state) Examine() {
 		switch {
 		case what.Is&Container == 0:
 			// Not a container
+		case what.Is&Locked == Locked:
+			s.MsgAppend(s.actor, " It is locked.")
 		case itemCount == 0 && what.Is&Narrative == Narrative:
 			// Don't describe empty narrative containers ;)
 		case itemCount == 0:
@@ -512,6 +636,12 @@ func (s *state) Inventory() {
 			s.Msg(s.actor.Ref[Where], text.Info, s.actor.As[UTheName], " checks over their gear.")
 		}
 	}
+	if s.actor.Int[Money] > 0 {
+		s.Msg(s.actor, text.Info, "You have ", coins(s.actor.Int[Money]), ".")
+	}
+	if msg := carrying(s.actor); msg != "" {
+		s.Msg(s.actor, text.Info, msg)
+	}
 }
 
 func (s *state) Drop() {
@@ -523,7 +653,8 @@ func (s *state) Drop() {
 
 	notify := len(s.actor.Ref[Where].Who) < cfg.crowdSize
 
-	for _, uid := range Match(s.word, s.actor) {
+	uids, counts := MatchCounts(s.word, s.actor)
+	for _, uid := range uids {
 		what := s.actor.In[uid]
 		switch {
 		case what == nil:
@@ -532,10 +663,14 @@ func (s *state) Drop() {
 			s.Msg(s.actor, text.Bad, what.As[VetoDrop])
 		case what.Is&Using != 0:
 			s.Msg(s.actor, text.Bad, "You can't drop ", what.As[TheName], " while using it.")
+		case !fits(what, s.actor.Ref[Where], counts[uid]):
+			s.tooHeavy(what, s.actor.Ref[Where], counts[uid])
 		default:
+			what = unstack(what, counts[uid])
 			delete(s.actor.In, what.As[UID])
 			s.actor.Ref[Where].In[what.As[UID]] = what
 			what.Schedule(Action)
+			what.Schedule(Wander)
 			what.Schedule(Cleanup)
 			what.Ref[Where] = s.actor.Ref[Where]
 			delete(what.As, DynamicQualifier)
@@ -543,6 +678,10 @@ func (s *state) Drop() {
 			if notify {
 				s.Msg(s.actor.Ref[Where], text.Info, s.actor.As[UTheName], " drops ", what.As[Name], ".")
 			}
+			if s.actor.Is&Player == Player {
+				s.hooks(OnDrop, what)
+			}
+			stack(what)
 		}
 	}
 }
@@ -556,12 +695,15 @@ func (s *state) Get() {
 
 	notify := len(s.actor.Ref[Where].Who) < cfg.crowdSize
 
-	for _, uid := range Match(s.word, s.actor.Ref[Where]) {
+	uids, counts := MatchCounts(s.word, s.actor.Ref[Where])
+	for _, uid := range uids {
 		what := s.actor.Ref[Where].In[uid]
 		if what == nil {
 			what = s.actor.Ref[Where].Who[uid]
 		}
 		switch {
+		case what == nil && !lit(s.actor.Ref[Where]):
+			s.Msg(s.actor, text.Bad, "It's too dark to find any '", uid, "' to get.")
 		case what == nil:
 			s.Msg(s.actor, text.Bad, "You see no '", uid, "' to get.")
 		case what.As[VetoGet] != "":
@@ -572,11 +714,16 @@ func (s *state) Get() {
 			s.Msg(s.actor, text.Bad, "You cannot take ", what.As[TheName], ".")
 		case what.Is&(NPC|Player) != 0 && len(what.Any[Holdable]) == 0:
 			s.Msg(s.actor, text.Bad, what.As[UTheName], " does not want to be taken!")
+		case !fits(what, s.actor, counts[uid]):
+			s.tooHeavy(what, s.actor, counts[uid])
 		default:
+			what = unstack(what, counts[uid])
 			what.Suspend(Action)
+			what.Suspend(Wander)
 			what.Cancel(Cleanup)
 			delete(s.actor.Ref[Where].In, what.As[UID])
 			what = what.Spawn()
+			what.Is &^= Hidden
 
 			// If item is a container it may have had items put in to it before it
 			// was spawned. In which case the items will have pending clean-ups that
@@ -592,6 +739,10 @@ func (s *state) Get() {
 			if notify {
 				s.Msg(s.actor.Ref[Where], text.Info, s.actor.As[UTheName], " picks up ", what.As[TheName], ".")
 			}
+			if s.actor.Is&Player == Player {
+				s.hooks(OnGet, what)
+			}
+			stack(what)
 		}
 	}
 }
@@ -623,6 +774,8 @@ func (s *state) Take() {
 		s.Msg(s.actor, text.Bad, where.As[UTheName], " does not want you taking anything of theirs!")
 	case where.Is&Container != Container:
 		s.Msg(s.actor, text.Bad, where.As[UTheName], " is not something you can take anything from.")
+	case where.Is&Locked == Locked:
+		s.Msg(s.actor, text.Bad, where.As[UTheName], " is locked.")
 	case len(words) == 0:
 		s.Msg(s.actor, text.Info, "You go to take something from ", where.As[TheName], ".")
 	case where.As[VetoTakeOut] != "":
@@ -633,7 +786,8 @@ func (s *state) Take() {
 	}
 
 	notify := false
-	for _, uid := range Match(words, where) {
+	uids, counts := MatchCounts(words, where)
+	for _, uid := range uids {
 		what := where.In[uid]
 		switch {
 		case what == nil:
@@ -642,7 +796,10 @@ func (s *state) Take() {
 			s.Msg(s.actor, text.Bad, what.As[VetoTake])
 		case what.Is&Narrative == Narrative:
 			s.Msg(s.actor, text.Bad, "You can't take ", what.As[TheName], " from ", where.As[TheName], ".")
+		case !fits(what, s.actor, counts[uid]):
+			s.tooHeavy(what, s.actor, counts[uid])
 		default:
+			what = unstack(what, counts[uid])
 			what.Cancel(Cleanup)
 			delete(where.In, what.As[UID])
 			what = what.Spawn()
@@ -650,10 +807,18 @@ func (s *state) Take() {
 			what.Ref[Where] = s.actor
 			what.As[DynamicQualifier] = "MY"
 			s.Msg(s.actor, text.Good, "You take ", what.As[TheName], " out of ", where.As[TheName], ".")
+			stack(what)
 			notify = true
 		}
 
 	}
+	// An emptied container, such as a corpse, waiting longer than usual to be
+	// cleaned up because of its content can now be cleaned up as usual.
+	if len(where.In) == 0 && where.Event[Cleanup] != nil &&
+		where.Int[CleanupDueAt]-time.Now().UnixNano() > where.Int[CleanupAfter] {
+		where.Schedule(Cleanup)
+	}
+
 	if notify && len(s.actor.Ref[Where].Who) < cfg.crowdSize {
 		if s.actor.In[where.As[UID]] == nil {
 			s.Msg(s.actor.Ref[Where], text.Info, s.actor.As[UTheName], " takes something out of ", where.As[TheName], ".")
@@ -690,6 +855,8 @@ func (s *state) Put() {
 		s.Msg(s.actor, text.Info, "Taxidermist are we?")
 	case where.Is&Container != Container:
 		s.Msg(s.actor, text.Bad, where.As[UTheName], " is not something you can put anything into.")
+	case where.Is&Locked == Locked:
+		s.Msg(s.actor, text.Bad, where.As[UTheName], " is locked.")
 	case len(words) == 0:
 		s.Msg(s.actor, text.Bad, "You go to put something into ", where.As[TheName], ".")
 	case where.As[VetoPutIn] != "":
@@ -702,7 +869,8 @@ func (s *state) Put() {
 	parent := where.Ref[Where]
 
 	notify := false
-	for _, uid := range Match(words, s.actor) {
+	uids, counts := MatchCounts(words, s.actor)
+	for _, uid := range uids {
 		what := s.actor.In[uid]
 		switch {
 		case what == nil:
@@ -716,7 +884,10 @@ func (s *state) Put() {
 		case uid == where.As[UID]:
 			s.Msg(s.actor, text.Info, "It might be interesting to put ", what.As[TheName],
 				" inside itself, but probably paradoxical as well.")
+		case !fits(what, where, counts[uid]):
+			s.tooHeavy(what, where, counts[uid])
 		default:
+			what = unstack(what, counts[uid])
 			delete(s.actor.In, what.As[UID])
 			where.In[what.As[UID]] = what
 			what.Ref[Where] = where
@@ -728,6 +899,7 @@ func (s *state) Put() {
 
 			delete(what.As, DynamicQualifier)
 			s.Msg(s.actor, text.Good, "You put ", what.As[TheName], " into ", where.As[TheName], ".")
+			stack(what)
 			notify = true
 		}
 	}
@@ -830,15 +1002,13 @@ func (s *state) Open() {
 			s.Msg(s.actor, text.Bad, what.As[UTheName], " is not something you can open.")
 		case what.Is&Open == Open:
 			s.Msg(s.actor, text.Bad, what.As[UTheName], " is already open.")
+		case what.Is&Locked == Locked:
+			s.Msg(s.actor, text.Bad, what.As[UTheName], " is locked.")
 		case s.actor != what && what.As[VetoOpen] != "":
 			s.Msg(s.actor, text.Bad, what.As[VetoOpen])
 		default:
 			what.Is |= Open
-			if what.Is&_Open == 0 {
-				what.Schedule(Trigger)
-			} else {
-				what.Cancel(Trigger)
-			}
+			blockerReset(what)
 
 			where := s.actor.Ref[Where]
 
@@ -889,11 +1059,7 @@ func (s *state) Close() {
 			s.Msg(s.actor, text.Bad, what.As[VetoClose])
 		default:
 			what.Is &^= Open
-			if what.Is&_Open == _Open {
-				what.Schedule(Trigger)
-			} else {
-				what.Cancel(Trigger)
-			}
+			blockerReset(what)
 
 			where := s.actor.Ref[Where]
 
@@ -923,6 +1089,335 @@ func (s *state) Close() {
 	}
 }
 
+// blockerReset schedules a Trigger event to reset a blocker, such as a door,
+// or a lockable item, such as a container, if its open or locked state differs
+// from its initial state. Nothing is scheduled if the blocker has no reset
+// period, see DOOR and LOCK in the zone file documentation. If the blocker is
+// back in its initial state any pending Trigger event is cancelled.
+func blockerReset(what *Thing) {
+	if (what.Is&Open == Open) != (what.Is&_Open == _Open) ||
+		(what.Is&Locked == Locked) != (what.Is&_Locked == _Locked) {
+		what.Schedule(Trigger)
+	} else {
+		what.Cancel(Trigger)
+	}
+}
+
+// lockKey returns the first item carried by the actor that is a key for the
+// passed item. If the actor has no key nil is returned.
+func (s *state) lockKey(what *Thing) *Thing {
+	for _, item := range s.actor.In.Sort() {
+		for _, ref := range what.Any[LockKeys] {
+			if item.As[Ref] == ref {
+				return item
+			}
+		}
+	}
+	return nil
+}
+
+func (s *state) LockItem() {
+	s.lockItem(true)
+}
+
+func (s *state) UnlockItem() {
+	s.lockItem(false)
+}
+
+// lockItem locks, or unlocks if lock is false, the items the actor specifies
+// using a key the actor is carrying. It implements the LOCK and UNLOCK
+// commands.
+func (s *state) lockItem(lock bool) {
+	verb, veto := "lock", VetoLock
+	if !lock {
+		verb, veto = "unlock", VetoUnlock
+	}
+
+	if len(s.word) == 0 {
+		s.Msg(s.actor, text.Info, "You go to ", verb, " something...")
+		return
+	}
+	where := s.actor.Ref[Where]
+	for _, uid := range Match(s.word, where, s.actor) {
+		what := where.In[uid]
+		if what == nil {
+			what = where.Who[uid]
+		}
+		if what == nil {
+			what = s.actor.In[uid]
+		}
+		var key *Thing
+		if what != nil && s.actor != what {
+			key = s.lockKey(what)
+		}
+		switch {
+		case what == nil:
+			s.Msg(s.actor, text.Bad, "You see no '", uid, "' to ", verb, ".")
+		case len(what.Any[LockKeys]) == 0:
+			s.Msg(s.actor, text.Bad, what.As[UTheName], " is not something you can ", verb, ".")
+		case lock && what.Is&Locked == Locked:
+			s.Msg(s.actor, text.Bad, what.As[UTheName], " is already locked.")
+		case !lock && what.Is&Locked == 0:
+			s.Msg(s.actor, text.Bad, what.As[UTheName], " is already unlocked.")
+		case lock && what.Is&Open == Open:
+			s.Msg(s.actor, text.Bad, "You need to close ", what.As[TheName], " before you can lock it.")
+		case s.actor != what && what.As[veto] != "":
+			s.Msg(s.actor, text.Bad, what.As[veto])
+		case s.actor != what && key == nil:
+			s.Msg(s.actor, text.Bad, "You don't have a key for ", what.As[TheName], ".")
+		default:
+			if lock {
+				what.Is |= Locked
+			} else {
+				what.Is &^= Locked
+			}
+			blockerReset(what)
+
+			if s.actor != what {
+				s.Msg(s.actor, text.Good, "You ", verb, " ", what.As[TheName], " with ", key.As[TheName], ".")
+			}
+
+			if len(where.Who) < cfg.crowdSize {
+				switch {
+				case s.actor == what:
+					s.Msg(where, text.Info, what.As[UTheName], " ", verb, "s with a click.")
+				case s.actor.In[what.As[UID]] != nil:
+					s.Msg(where, text.Info, s.actor.As[UTheName], " ", verb, "s ", what.As[Name], " they are carrying.")
+				default:
+					s.Msg(where, text.Info, s.actor.As[UTheName], " ", verb, "s ", what.As[TheName], ".")
+				}
+			}
+
+			// Find location on other side of a blocker...
+			if what.As[Blocker] == "" {
+				break
+			}
+			if where == what.Ref[Where] {
+				where = where.Ref[NameToDir[what.As[Blocker]]]
+			} else {
+				where = what.Ref[Where]
+			}
+			if len(where.Who) < cfg.crowdSize {
+				s.Msg(where, text.Info, what.As[UTheName], " ", verb, "s with a click.")
+			}
+		}
+	}
+}
+
+func (s *state) LightItem() {
+	if len(s.word) == 0 {
+		s.Msg(s.actor, text.Info, "You go to light something...")
+		return
+	}
+	where := s.actor.Ref[Where]
+	for _, uid := range Match(s.word, s.actor, where) {
+		what := s.actor.In[uid]
+		if what == nil {
+			what = where.In[uid]
+		}
+		if what == nil {
+			what = where.Who[uid]
+		}
+		switch {
+		case what == nil:
+			s.Msg(s.actor, text.Bad, "You see no '", uid, "' to light.")
+		case what.Is&Light != Light:
+			s.Msg(s.actor, text.Bad, what.As[UTheName], " is not something you can light.")
+		case what.Is&Lit == Lit:
+			s.Msg(s.actor, text.Bad, what.As[UTheName], " is already lit.")
+		case what.As[VetoLight] != "":
+			s.Msg(s.actor, text.Bad, what.As[VetoLight])
+		default:
+			dark := !lit(where)
+			what.Is |= Lit
+			what.Schedule(Burn)
+
+			s.Msg(s.actor, text.Good, "You light ", what.As[TheName], ".")
+			if len(where.Who) < cfg.crowdSize {
+				s.Msg(where, text.Info, s.actor.As[UTheName], " lights ", what.As[TheName], ".")
+				if dark {
+					s.MsgAppend(where, " The darkness retreats.")
+				}
+			}
+		}
+	}
+}
+
+func (s *state) Extinguish() {
+	if len(s.word) == 0 {
+		s.Msg(s.actor, text.Info, "You go to extinguish something...")
+		return
+	}
+	where := s.actor.Ref[Where]
+	for _, uid := range Match(s.word, s.actor, where) {
+		what := s.actor.In[uid]
+		if what == nil {
+			what = where.In[uid]
+		}
+		if what == nil {
+			what = where.Who[uid]
+		}
+		switch {
+		case what == nil:
+			s.Msg(s.actor, text.Bad, "You see no '", uid, "' to extinguish.")
+		case what.Is&Light != Light:
+			s.Msg(s.actor, text.Bad, what.As[UTheName], " is not something you can extinguish.")
+		case what.Is&Lit != Lit:
+			s.Msg(s.actor, text.Bad, what.As[UTheName], " is not lit.")
+		case what.As[VetoExtinguish] != "":
+			s.Msg(s.actor, text.Bad, what.As[VetoExtinguish])
+		default:
+			what.Is &^= Lit
+			what.Suspend(Burn)
+
+			s.Msg(s.actor, text.Good, "You extinguish ", what.As[TheName], ".")
+			if len(where.Who) < cfg.crowdSize {
+				s.Msg(where, text.Info, s.actor.As[UTheName], " extinguishes ", what.As[TheName], ".")
+				if !lit(where) {
+					s.MsgAppend(where, " Darkness closes in.")
+				}
+			}
+		}
+	}
+}
+
+func (s *state) Search() {
+	where := s.actor.Ref[Where]
+
+	if !lit(where) {
+		s.Msg(s.actor, text.Bad, "It's too dark to search for anything here.")
+		return
+	}
+
+	if len(where.Who) < cfg.crowdSize {
+		s.Msg(where, text.Info, s.actor.As[UTheName], " starts searching around.")
+	}
+
+	now := time.Now().UnixNano()
+	searched := len(s.actor.Any[Found])
+	for _, item := range where.In.Sort() {
+		if !hiddenItem(s.actor, item) || rand.Int63n(100) >= item.Int[HiddenChance] {
+			continue
+		}
+		at := now + item.Int[HiddenReveal]
+		s.actor.Any[Found] = append(s.actor.Any[Found], item.As[UID]+"→"+strconv.FormatInt(at, 10))
+		s.Msg(s.actor, text.Good, "You find ", item.As[Name], ".")
+	}
+
+	for dir := North; dir <= Down; dir++ {
+		if where.Ref[dir] == nil || !hiddenExit(s.actor, where, dir) ||
+			rand.Int63n(100) >= where.Int[HiddenChance] {
+			continue
+		}
+		at := now + where.Int[HiddenReveal]
+		s.actor.Any[Found] = append(s.actor.Any[Found], where.As[UID]+":"+DirToName[dir]+"→"+strconv.FormatInt(at, 10))
+		s.Msg(s.actor, text.Good, "You find a hidden exit leading ", DirToName[dir], ".")
+	}
+
+	if len(s.actor.Any[Found]) == searched {
+		s.Msg(s.actor, text.Info, "You search around but find nothing.")
+		return
+	}
+
+	scheduleForget(s.actor)
+}
+
+// splitDue splits an entry of the form "ID→due at", such as a hidden thing
+// stored in Thing.Any[Found] or a cool-down stored in Thing.Any[Cooldowns],
+// into the ID and the time, in Unix nanoseconds, the entry is due.
+func splitDue(entry string) (string, int64) {
+	parts := strings.SplitN(entry, "→", 2)
+	if len(parts) != 2 {
+		return parts[0], 0
+	}
+	at, _ := strconv.ParseInt(parts[1], 10, 64)
+	return parts[0], at
+}
+
+// found returns true if the hidden item or exit with the passed ID has been
+// found by the actor using SEARCH and has not been forgotten yet.
+func found(actor *Thing, id string) bool {
+	for _, entry := range actor.Any[Found] {
+		if ref, _ := splitDue(entry); ref == id {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleForget schedules who's Forget event for when the next hidden item
+// or exit found by who is due to be forgotten. If who has not found anything
+// the Forget event is cancelled.
+func scheduleForget(who *Thing) {
+	next := int64(0)
+	for _, entry := range who.Any[Found] {
+		if _, at := splitDue(entry); next == 0 || at < next {
+			next = at
+		}
+	}
+	who.Cancel(Forget)
+	if next == 0 {
+		delete(who.Any, Found)
+		delete(who.Int, ForgetAfter)
+		return
+	}
+	wait := time.Until(time.Unix(0, next)).Nanoseconds()
+	if wait < 1 {
+		wait = 1
+	}
+	who.Int[ForgetAfter] = wait
+	who.Schedule(Forget)
+}
+
+// hiddenItem returns true if the passed item is hidden and has not been found
+// by the actor using SEARCH.
+func hiddenItem(actor, item *Thing) bool {
+	if item.Is&Hidden != Hidden {
+		return false
+	}
+	return actor == nil || !found(actor, item.As[UID])
+}
+
+// hiddenExit returns true if the exit in the given direction from the passed
+// location is hidden and has not been found by the actor using SEARCH. NPCs
+// know their way around and are never hindered by hidden exits.
+func hiddenExit(actor, where *Thing, dir refKey) bool {
+	if actor.Is&NPC == NPC {
+		return false
+	}
+	for _, hidden := range where.Any[HiddenExits] {
+		if NameToDir[hidden] == dir {
+			return !found(actor, where.As[UID]+":"+DirToName[dir])
+		}
+	}
+	return false
+}
+
+// lit returns true if the passed location can be seen. A location can be seen
+// if it is not dark, or if a dark location is lit by a light source. A light
+// source can be at the location or carried by anyone at the location.
+func lit(where *Thing) bool {
+	if where.Is&Dark != Dark {
+		return true
+	}
+	for _, inv := range []Things{where.In, where.Who} {
+		for _, item := range inv {
+			if item.Is&Lit == Lit {
+				return true
+			}
+			if item.Is&(Player|NPC) == 0 {
+				continue
+			}
+			for _, carried := range item.In {
+				if carried.Is&Lit == Lit {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
 func (s *state) Commands() {
 	cols := 7
 	split := (len(commandNames) / cols) + 1
@@ -973,6 +1468,11 @@ func (s *state) Poof() {
 	if s.actor.Int[HealthCurrent] < s.actor.Int[HealthMaximum] {
 		s.actor.Schedule(Health)
 	}
+	if s.actor.Int[ManaCurrent] < s.actor.Int[ManaMaximum] {
+		s.actor.Schedule(Mana)
+	}
+	scheduleCooldown(s.actor)
+	startHunger(s.actor)
 
 	if len(s.actor.Ref[Where].Who) < cfg.crowdSize {
 		s.Msg(s.actor.Ref[Where], text.Info, "There is a cloud of smoke from which ",
@@ -1019,10 +1519,79 @@ func (s *state) Say() {
 			s.Msg(where, text.Info, "You hear talking nearby.")
 		}
 	}
+
+	if s.actor.Is&Player != Player {
+		return
+	}
+
+	// Run OnSay hooks for keywords said, ignoring case and punctuation
+	said := " " + strings.Join(strings.FieldsFunc(strings.ToUpper(s.input),
+		func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}), " ") + " "
+	for _, t := range append([]*Thing{where}, where.In.Sort()...) {
+		for _, hook := range t.Any[OnSay] {
+			keyword, cmd, _ := strings.Cut(hook, "→")
+			keyword = strings.ToUpper(strings.TrimSpace(keyword))
+			if keyword != "" && strings.Contains(said, " "+keyword+" ") {
+				s.hook(t, strings.TrimSpace(cmd))
+			}
+		}
+	}
+}
+
+// Echo displays a message at the actor's location. If the actor is a location
+// the message is displayed at the location. If the actor is being carried, or
+// is in a container, the message is displayed at the enclosing location.
+func (s *state) Echo() {
+	where := s.actor
+	for where.Is&Location != Location && where.Ref[Where] != nil {
+		where = where.Ref[Where]
+	}
+	if len(s.input) > 0 && len(where.Who) < cfg.crowdSize {
+		s.Msg(where, text.Info, s.input)
+	}
+}
+
+// hooks runs the scripted commands for a hook, such as OnEnter, for the passed
+// things in response to the actor. See hook for details.
+func (s *state) hooks(key anyKey, things ...*Thing) {
+	for _, t := range things {
+		for _, cmd := range t.Any[key] {
+			s.hook(t, cmd)
+		}
+	}
+}
+
+// hook runs a scripted command for the passed thing, with the thing as the
+// actor, using subparseFor. The command may use Message substitutions, with
+// the current actor as %A and the thing as %D, and the observer's view is
+// used. For a $ECHO command the current actor sees the actor's view of the
+// message instead. Locations can only use $ECHO as other commands expect the
+// actor to be somewhere.
+func (s *state) hook(t *Thing, cmd string) {
+	if t.Is&Freed == Freed {
+		return
+	}
+	a, _, o := Message(s.actor, t, cmd)
+	switch {
+	case strings.HasPrefix(o, "$ECHO "):
+		s.Msg(s.actor, text.Info, strings.TrimPrefix(a, "$ECHO "))
+		s2 := &state{actor: t, buf: s.buf}
+		s2.parse(o, withScripting)
+	case t.Is&Location == Location:
+		log.Printf("[%s] Hook ignored, locations can only use $ECHO: %s", t.As[UID], cmd)
+	default:
+		s.subparseFor(t, o)
+	}
 }
 
 func (s *state) Action() {
 	s.actor.Cancel(Action)
+	if s.aggress() || s.aid() {
+		return
+	}
+
 	l := len(s.actor.Any[OnAction])
 	if l == 0 {
 		return
@@ -1035,6 +1604,49 @@ func (s *state) Action() {
 	}
 }
 
+// Wander moves an NPC through a random exit from its current location. Exits
+// that are blocked, or lead to locations outside of the NPC's wander limits,
+// are not considered. NPCs do not wander while fighting.
+func (s *state) Wander() {
+	s.actor.Cancel(Wander)
+	if s.actor.Is&NPC != NPC || len(s.actor.Any[Opponents]) > 0 {
+		return
+	}
+
+	if where := s.actor.Ref[Where]; where != nil && where.Is&Location == Location {
+		dirs := []refKey{}
+		for dir := North; dir <= Down; dir++ {
+			if where.Ref[dir] != nil && canWander(s.actor, where.Ref[dir]) && s.blocked(dir) == "" {
+				dirs = append(dirs, dir)
+			}
+		}
+		if len(dirs) > 0 {
+			s.subparse(DirToName[dirs[rand.Intn(len(dirs))]])
+		}
+	}
+
+	s.actor.Schedule(Wander)
+}
+
+// canWander returns true if the passed NPC may wander into the passed
+// location, otherwise false. If the NPC has no wander limits it may wander
+// anywhere. Otherwise the location must be in one of the NPC's limiting zones
+// or be one of the NPC's limiting locations.
+func canWander(npc, where *Thing) bool {
+	if len(npc.Any[WanderLimit]) == 0 {
+		return true
+	}
+	for _, limit := range npc.Any[WanderLimit] {
+		if limit == where.As[Ref] {
+			return true
+		}
+		if strings.HasSuffix(limit, ":") && strings.HasPrefix(where.As[Ref], limit) {
+			return true
+		}
+	}
+	return false
+}
+
 // FIXME(diddymus): Currently SNEEZE has very aggressive crowd control to limit
 // the amount of broadcasting we do, otherwise network traffic and CPU usage
 // goes through the roof.
@@ -1140,8 +1752,34 @@ func (s *state) Reset() {
 
 	where := s.actor.Ref[Where]
 	parent := where.Ref[Where]
+
+	// Restore initial lit state of a light source, Init will schedule a fresh
+	// burn if needed.
+	s.actor.Cancel(Burn)
+	if s.actor.Is&_Lit == _Lit {
+		s.actor.Is |= Lit
+	} else {
+		s.actor.Is &^= Lit
+	}
+
 	s.actor.Init()
 	delete(where.Out, s.actor.As[UID])
+
+	// Restore initial locked state, e.g. of a container, a pending reset of
+	// the locked state is no longer needed.
+	if s.actor.Is&_Locked == _Locked {
+		s.actor.Is |= Locked
+	} else {
+		s.actor.Is &^= Locked
+	}
+	s.actor.Cancel(Trigger)
+
+	// Restore initial hidden state
+	if s.actor.Is&_Hidden == _Hidden {
+		s.actor.Is |= Hidden
+	} else {
+		s.actor.Is &^= Hidden
+	}
 	where.In[s.actor.As[UID]] = s.actor
 
 	if s.actor.Int[HealthCurrent] < s.actor.Int[HealthMaximum] {
@@ -1149,6 +1787,15 @@ func (s *state) Reset() {
 		s.actor.Int[HealthCurrent] = s.actor.Int[HealthMaximum]
 	}
 
+	// Refill drink containers
+	s.actor.Int[Sips] = s.actor.Int[SipsMaximum]
+
+	// Restore initial quantity of a stack
+	if s.actor.Int[QuantityReset] > 0 {
+		s.actor.Int[Quantity] = s.actor.Int[QuantityReset]
+		s.actor.restack()
+	}
+
 	// Check parent of where reset will happen to see if where is out of play.
 	// If where is out of play reset will not be seen. However, if where reset
 	// will happen now has no out of play items we can schedule a reset for it.
@@ -1196,7 +1843,8 @@ func (s *state) Junk() {
 
 	notify := len(s.actor.Ref[Where].Who) < cfg.crowdSize
 
-	for _, uid := range Match(s.word, s.actor) {
+	uids, counts := MatchCounts(s.word, s.actor)
+	for _, uid := range uids {
 		what := s.actor.In[uid]
 		switch {
 		case what == nil:
@@ -1206,6 +1854,7 @@ func (s *state) Junk() {
 		case what.Is&Using != 0:
 			s.Msg(s.actor, text.Bad, "You can't junk ", what.As[TheName], " while using it.")
 		default:
+			what = unstack(what, counts[uid])
 			s.Msg(s.actor, text.Good, "You junk ", what.As[TheName], ".")
 			if notify {
 				s.Msg(s.actor.Ref[Where], text.Info, s.actor.As[UTheName], " junks ", what.As[Name], ".")
@@ -1243,12 +1892,83 @@ func (s *state) Trigger() {
 
 	switch s.actor.As[TriggerType] {
 	case "BLOCKER":
-		if s.actor.Is&_Open == _Open {
-			s.subparse("OPEN " + s.actor.As[UID])
-		} else {
-			s.subparse("CLOSE " + s.actor.As[UID])
+		uid := s.actor.As[UID]
+		switch {
+		case s.actor.As[Blocker] == "":
+			// Not a door, only the locked state of a container is reset
+		case s.actor.Is&_Open == _Open:
+			if s.actor.Is&Locked == Locked {
+				s.subparse("UNLOCK " + uid)
+			}
+			s.subparse("OPEN " + uid)
+			return
+		default:
+			s.subparse("CLOSE " + uid)
+		}
+		switch {
+		case s.actor.Is&_Locked == _Locked && s.actor.Is&Locked == 0:
+			s.subparse("LOCK " + uid)
+		case s.actor.Is&_Locked == 0 && s.actor.Is&Locked == Locked:
+			s.subparse("UNLOCK " + uid)
+		}
+	}
+}
+
+// release forcibly stops who from holding, wearing or wielding the passed
+// item, giving the body slots used by the item back to who.
+func release(who, item *Thing) {
+	var slots []string
+	switch {
+	case item.Is&Holding == Holding:
+		slots = item.Any[Holdable]
+	case item.Is&Wearing == Wearing:
+		slots = item.Any[Wearable]
+	case item.Is&Wielding == Wielding:
+		slots = item.Any[Wieldable]
+	}
+	if len(slots) > 0 {
+		who.Any[Body] = append(who.Any[Body], slots...)
+	}
+	item.Is &^= Using
+}
+
+// Burn is used to process a burn event for a light source, when the light
+// source burns out. The burnt out light source is junked and will reset like
+// any other junked item.
+func (s *state) Burn() {
+	s.actor.Cancel(Burn)
+	s.actor.Is &^= Lit
+
+	where := s.actor.Ref[Where]
+	switch {
+	case where.Is&(Player|NPC) != 0:
+		s.Msg(where, text.Info, s.actor.As[UTheName], " you are carrying burns out.")
+		if loc := where.Ref[Where]; len(loc.Who) < cfg.crowdSize {
+			s.Msg(loc, text.Info, s.actor.As[UTheName], " carried by ", where.As[TheName], " burns out.")
+		}
+
+		// Forcibly remove used item, giving the body slots back
+		release(where, s.actor)
+	case where.Is&Location == Location && len(where.Who) < cfg.crowdSize:
+		s.Msg(where, text.Info, s.actor.As[UTheName], " burns out.")
+	}
+
+	s.actor.Junk()
+}
+
+// Forget is used to process a forget event for a player. Hidden items and
+// exits the player found using SEARCH that are due to be forgotten become
+// hidden again. Each is forgotten when its own REVEAL period has passed.
+func (s *state) Forget() {
+	now := time.Now().UnixNano()
+	keep := s.actor.Any[Found][:0]
+	for _, entry := range s.actor.Any[Found] {
+		if _, at := splitDue(entry); at > now {
+			keep = append(keep, entry)
 		}
 	}
+	s.actor.Any[Found] = keep
+	scheduleForget(s.actor)
 }
 
 func (s *state) Remove() {
@@ -1553,7 +2273,7 @@ func (s *state) Health() {
 		return
 	}
 
-	s.actor.Int[HealthCurrent] += s.actor.Int[HealthRestore]
+	s.actor.Int[HealthCurrent] += healthRestore(s.actor)
 
 	if s.actor.Int[HealthCurrent] >= s.actor.Int[HealthMaximum] {
 		s.actor.Int[HealthCurrent] = s.actor.Int[HealthMaximum]
@@ -1664,7 +2384,7 @@ func (s state) Who() {
 		if uid == auid {
 			continue
 		}
-		s.MsgAppend(s.actor, "␠␠", player.As[Name], "\n")
+		s.MsgAppend(s.actor, "␠␠", player.As[Name], pvpMark(player), "\n")
 	}
 	s.Msg(s.actor, text.Good, "Current player population: ", pop)
 }
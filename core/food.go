@@ -0,0 +1,269 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package core
+
+import (
+	"code.wolfmud.org/WolfMUD.git/text"
+)
+
+// Player food and water levels. Levels range from 0 (starving or parched) to
+// fullLevel. At or below hungryLevel a player is hungry or thirsty.
+const (
+	fullLevel   = 100
+	hungryLevel = 25
+)
+
+// healthRestore returns the amount of health restored for who by a Health
+// event. If hunger is enabled a player who is hungry or thirsty restores half
+// as much health for each, a player who is starving or parched does not
+// restore any health.
+func healthRestore(who *Thing) int64 {
+	restore := who.Int[HealthRestore]
+	if cfg.hungerRate == 0 || who.Is&Player != Player {
+		return restore
+	}
+	for _, level := range []int64{who.Int[FoodLevel], who.Int[WaterLevel]} {
+		switch {
+		case level == 0:
+			return 0
+		case level <= hungryLevel:
+			restore /= 2
+		}
+	}
+	return restore
+}
+
+// hungerStatus returns a description of how hungry and thirsty who is, or an
+// empty string if who is neither or hunger is disabled.
+func hungerStatus(who *Thing) string {
+	if cfg.hungerRate == 0 {
+		return ""
+	}
+	var food, water string
+	switch level := who.Int[FoodLevel]; {
+	case level == 0:
+		food = "starving"
+	case level <= hungryLevel:
+		food = "hungry"
+	}
+	switch level := who.Int[WaterLevel]; {
+	case level == 0:
+		water = "parched"
+	case level <= hungryLevel:
+		water = "thirsty"
+	}
+	switch {
+	case food != "" && water != "":
+		return "You are " + food + " and " + water + "."
+	case food != "":
+		return "You are " + food + "."
+	case water != "":
+		return "You are " + water + "."
+	}
+	return ""
+}
+
+// startHunger sets up and schedules the Hunger event for a player entering
+// the world, if hunger is enabled. Players without food and water levels, for
+// example from before hunger was added, start well fed and watered.
+func startHunger(who *Thing) {
+	if _, ok := who.Int[FoodLevel]; !ok {
+		who.Int[FoodLevel] = fullLevel
+	}
+	if _, ok := who.Int[WaterLevel]; !ok {
+		who.Int[WaterLevel] = fullLevel
+	}
+	if cfg.hungerRate > 0 {
+		who.Int[HungerAfter] = cfg.hungerRate
+		who.Schedule(Hunger)
+	}
+}
+
+// Hunger reduces a player's food and water levels, it is run for the
+// player's Hunger event. The player is notified on becoming hungry or
+// thirsty, and again on starving or becoming parched.
+func (s *state) Hunger() {
+	s.actor.Cancel(Hunger)
+	if s.actor.Is&Player != Player || cfg.hungerRate == 0 {
+		return
+	}
+
+	if level := s.actor.Int[FoodLevel]; level > 0 {
+		s.actor.Int[FoodLevel]--
+		switch level - 1 {
+		case hungryLevel:
+			s.Msg(s.actor, text.Bad, "You are getting hungry.")
+		case 0:
+			s.Msg(s.actor, text.Bad, "You are starving!")
+		}
+	}
+
+	if level := s.actor.Int[WaterLevel]; level > 0 {
+		s.actor.Int[WaterLevel]--
+		switch level - 1 {
+		case hungryLevel:
+			s.Msg(s.actor, text.Bad, "You are getting thirsty.")
+		case 0:
+			s.Msg(s.actor, text.Bad, "You are parched!")
+		}
+	}
+
+	s.actor.Schedule(Hunger)
+}
+
+// refresh applies the food or water level increase, and any health restored,
+// from eating or drinking an item.
+func (s *state) refresh(level intKey, amount, heal int64) {
+	s.actor.Int[level] += amount
+	if s.actor.Int[level] > fullLevel {
+		s.actor.Int[level] = fullLevel
+	}
+
+	if heal > 0 && s.actor.Int[HealthCurrent] < s.actor.Int[HealthMaximum] {
+		s.actor.Int[HealthCurrent] += heal
+		if s.actor.Int[HealthCurrent] >= s.actor.Int[HealthMaximum] {
+			s.actor.Int[HealthCurrent] = s.actor.Int[HealthMaximum]
+			s.actor.Cancel(Health)
+		}
+		s.Msg(s.actor, text.Good, "You feel better.")
+		s.StatusUpdate(s.actor)
+	}
+}
+
+// Eat eats food being carried. Food eaten is junked. Only one item is eaten
+// from a stack of food, asking to eat more than one is refused.
+func (s *state) Eat() {
+
+	if len(s.word) == 0 {
+		s.Msg(s.actor, text.Info, "You go to eat... something?")
+		return
+	}
+
+	notify := len(s.actor.Ref[Where].Who) < cfg.crowdSize
+
+	uids, counts := MatchCounts(s.word, s.actor)
+	for _, uid := range uids {
+		what := s.actor.In[uid]
+		switch {
+		case what == nil:
+			s.Msg(s.actor, text.Bad, "You do not have any '", uid, "' to eat.")
+		case counts[uid] > 1:
+			s.Msg(s.actor, text.Info, "You can only eat one thing at a time.")
+		case what.Is&Edible != Edible:
+			s.Msg(s.actor, text.Bad, "You can't eat ", what.As[TheName], ".")
+		case what.Is&(Wearing|Wielding) != 0:
+			s.Msg(s.actor, text.Bad, "You can't eat ", what.As[TheName], " while using it.")
+		case cfg.hungerRate > 0 && what.Int[Nutrition] > 0 && s.actor.Int[FoodLevel] >= fullLevel:
+			s.Msg(s.actor, text.Info, "You are too full to eat ", what.As[TheName], ".")
+		default:
+			what = unstack(what, 1)
+			s.Msg(s.actor, text.Good, "You eat ", what.As[TheName], ".")
+			if notify {
+				s.Msg(s.actor.Ref[Where], text.Info, s.actor.As[UTheName], " eats ", what.As[Name], ".")
+			}
+			s.refresh(FoodLevel, what.Int[Nutrition], what.Int[Heal])
+			what.Junk()
+		}
+	}
+}
+
+// Drink drinks from something being carried or at the actor's location. A
+// drink container has a limited number of drinks, something without a limit
+// such as a fountain can be drunk from as often as wanted. Drinking from a
+// stack of drink containers only drinks from one of the containers.
+func (s *state) Drink() {
+
+	if len(s.word) == 0 {
+		s.Msg(s.actor, text.Info, "You go to drink... something?")
+		return
+	}
+
+	where := s.actor.Ref[Where]
+	notify := len(where.Who) < cfg.crowdSize
+
+	for _, uid := range Match(s.word, s.actor, where) {
+		what := s.actor.In[uid]
+		if what == nil {
+			what = where.In[uid]
+		}
+		switch {
+		case what == nil && !lit(where):
+			s.Msg(s.actor, text.Bad, "It's too dark to find any '", uid, "' to drink.")
+		case what == nil:
+			s.Msg(s.actor, text.Bad, "You see no '", uid, "' to drink.")
+		case what.Is&Drinkable != Drinkable:
+			s.Msg(s.actor, text.Bad, "You can't drink from ", what.As[TheName], ".")
+		case what.Int[SipsMaximum] > 0 && what.Int[Sips] == 0:
+			s.Msg(s.actor, text.Info, what.As[UTheName], " is empty.")
+		case cfg.hungerRate > 0 && what.Int[Hydration] > 0 && s.actor.Int[WaterLevel] >= fullLevel:
+			s.Msg(s.actor, text.Info, "You are not thirsty enough to drink from ", what.As[TheName], ".")
+		default:
+			if what.Int[SipsMaximum] > 0 {
+				what = unstack(what, 1)
+			}
+			s.Msg(s.actor, text.Good, "You drink from ", what.As[TheName], ".")
+			if notify {
+				s.Msg(where, text.Info, s.actor.As[UTheName], " drinks from ", what.As[Name], ".")
+			}
+			if what.Int[SipsMaximum] > 0 {
+				if what.Int[Sips]--; what.Int[Sips] == 0 {
+					s.Msg(s.actor, text.Info, what.As[UTheName], " is now empty.")
+				}
+			}
+			s.refresh(WaterLevel, what.Int[Hydration], what.Int[Heal])
+		}
+	}
+}
+
+// Fill refills drink containers being carried from a source of drink, such
+// as a fountain, at the actor's location. A source of drink is an item at the
+// location that can be drunk from as often as wanted. Filled containers hold
+// whatever the source provides. One container may be filled from a stack of
+// containers, asking to fill more than one is refused.
+func (s *state) Fill() {
+
+	if len(s.word) == 0 {
+		s.Msg(s.actor, text.Info, "You go to fill... something?")
+		return
+	}
+
+	where := s.actor.Ref[Where]
+	var source *Thing
+	for _, item := range where.In.Sort() {
+		if item.Is&Drinkable == Drinkable && item.Int[SipsMaximum] == 0 {
+			source = item
+			break
+		}
+	}
+
+	notify := len(where.Who) < cfg.crowdSize
+
+	uids, counts := MatchCounts(s.word, s.actor)
+	for _, uid := range uids {
+		what := s.actor.In[uid]
+		switch {
+		case what == nil:
+			s.Msg(s.actor, text.Bad, "You do not have any '", uid, "' to fill.")
+		case what.Is&Drinkable != Drinkable || what.Int[SipsMaximum] == 0:
+			s.Msg(s.actor, text.Bad, "You can't fill ", what.As[TheName], ".")
+		case counts[uid] > 1:
+			s.Msg(s.actor, text.Info, "You can only fill one thing at a time.")
+		case source == nil:
+			s.Msg(s.actor, text.Bad, "There is nothing here to fill ", what.As[TheName], " from.")
+		case what.Int[Sips] == what.Int[SipsMaximum]:
+			s.Msg(s.actor, text.Info, what.As[UTheName], " is already full.")
+		default:
+			what = unstack(what, 1)
+			what.Int[Sips] = what.Int[SipsMaximum]
+			what.Int[Hydration] = source.Int[Hydration]
+			what.Int[Heal] = source.Int[Heal]
+			s.Msg(s.actor, text.Good, "You fill ", what.As[TheName], " from ", source.As[TheName], ".")
+			if notify {
+				s.Msg(where, text.Info, s.actor.As[UTheName], " fills ", what.As[Name], ".")
+			}
+		}
+	}
+}
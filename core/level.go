@@ -0,0 +1,152 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package core
+
+import (
+	"log"
+	"sort"
+	"strconv"
+
+	"code.wolfmud.org/WolfMUD.git/recordjar"
+	"code.wolfmud.org/WolfMUD.git/recordjar/decode"
+	"code.wolfmud.org/WolfMUD.git/text"
+)
+
+// level is a player experience level. The health, mana, armour and damage
+// values are increases applied to a player's stats on reaching the level.
+type level struct {
+	experience   int64  // Experience needed to reach the level
+	title        string // Title for players at the level
+	health       int64  // Increase in maximum health
+	mana         int64  // Increase in maximum mana
+	armour       int64  // Increase in armour
+	damageFixed  int64  // Increase in fixed damage
+	damageRandom int64  // Increase in random damage
+}
+
+// levels are the player experience levels ordered by experience required.
+// Level 1 is levels[0]. Set by SetLevels.
+var levels []level
+
+// SetLevels sets the player experience levels from the passed recordjar, one
+// record per level. Levels are numbered from 1 in order of the experience
+// required to reach them. SetLevels should be called before any players
+// enter the world.
+func SetLevels(jar recordjar.Jar) {
+	levels = levels[:0]
+	for _, r := range jar {
+		if len(r["EXPERIENCE"]) == 0 {
+			continue
+		}
+		l := level{
+			experience: int64(decode.Integer(r["EXPERIENCE"])),
+			title:      decode.String(r["TITLE"]),
+			health:     int64(decode.Integer(r["HEALTH"])),
+			mana:       int64(decode.Integer(r["MANA"])),
+			armour:     int64(decode.Integer(r["ARMOUR"])),
+		}
+		fixed, random := decode.DoubleInteger(r["DAMAGE"])
+		l.damageFixed, l.damageRandom = int64(fixed), int64(random)
+		levels = append(levels, l)
+	}
+	sort.SliceStable(levels, func(i, j int) bool {
+		return levels[i].experience < levels[j].experience
+	})
+	log.Printf("Loaded experience levels: %d", len(levels))
+}
+
+// currentLevel returns the current experience level of the passed player.
+// Players without a level, for example from before levels were added, are
+// level 1.
+func currentLevel(who *Thing) int64 {
+	if who.Int[Level] < 1 {
+		return 1
+	}
+	return who.Int[Level]
+}
+
+// title returns the title for the passed level, or an empty string if there
+// is no title.
+func title(lvl int64) string {
+	if lvl < 1 || int(lvl) > len(levels) {
+		return ""
+	}
+	return levels[lvl-1].title
+}
+
+// experience awards the passed amount of experience to a player. If the
+// player has enough experience to reach the next level, or levels, the
+// increases for each level reached are applied and the player notified.
+func (s *state) experience(who *Thing, amount int64) {
+	if who.Is&Player != Player || amount < 1 {
+		return
+	}
+
+	who.Int[Experience] += amount
+	s.Msg(who, text.Good, "You gain ", strconv.FormatInt(amount, 10), " experience.")
+
+	lvl := currentLevel(who)
+	for int(lvl) < len(levels) && who.Int[Experience] >= levels[lvl].experience {
+		l := levels[lvl]
+		lvl++
+		who.Int[Level] = lvl
+		who.Int[HealthMaximum] += l.health
+		who.Int[HealthCurrent] += l.health
+		if who.Int[ManaMaximum] > 0 {
+			who.Int[ManaMaximum] += l.mana
+			who.Int[ManaCurrent] += l.mana
+		}
+		who.Int[Armour] += l.armour
+		who.Int[DamageFixed] += l.damageFixed
+		who.Int[DamageRandom] += l.damageRandom
+
+		s.Msg(who, text.Good, "You have reached level ", strconv.FormatInt(lvl, 10), "!")
+		if l.title != "" {
+			s.Msg(who, text.Good, "You are now known as ", who.As[Name], " the ", l.title, ".")
+		}
+		s.Log("%s reached level %d", who.As[Name], lvl)
+	}
+	s.StatusUpdate(who)
+}
+
+// Score displays the actor's level, experience and current stats.
+func (s *state) Score() {
+	lvl := currentLevel(s.actor)
+	name := s.actor.As[Name]
+	if t := title(lvl); t != "" {
+		name += " the " + t
+	}
+	s.Msg(s.actor, text.Info, "You are ", name, ", level ", strconv.FormatInt(lvl, 10), ".")
+
+	xp := strconv.FormatInt(s.actor.Int[Experience], 10)
+	if int(lvl) < len(levels) {
+		next := strconv.FormatInt(levels[lvl].experience, 10)
+		s.Msg(s.actor, text.Info, "Experience: ", xp, ", next level at ", next, ".")
+	} else {
+		s.Msg(s.actor, text.Info, "Experience: ", xp, ".")
+	}
+
+	s.Msg(s.actor, text.Info,
+		"Health: ", strconv.FormatInt(s.actor.Int[HealthCurrent], 10),
+		"/", strconv.FormatInt(s.actor.Int[HealthMaximum], 10),
+		", armour: ", strconv.FormatInt(armour(s.actor), 10),
+		", damage: ", strconv.FormatInt(damageFixed(s.actor), 10),
+		"+", strconv.FormatInt(damageRandom(s.actor), 10), ".",
+	)
+	if s.actor.Int[ManaMaximum] > 0 {
+		s.Msg(s.actor, text.Info,
+			"Mana: ", strconv.FormatInt(s.actor.Int[ManaCurrent], 10),
+			"/", strconv.FormatInt(s.actor.Int[ManaMaximum], 10), ".",
+		)
+	}
+	if name := s.actor.As[BuffName]; name != "" {
+		s.Msg(s.actor, text.Info, "You are under the effects of ", name, ".")
+	}
+	s.Msg(s.actor, text.Info, "Money: ", coins(s.actor.Int[Money]), ".")
+	if status := hungerStatus(s.actor); status != "" {
+		s.Msg(s.actor, text.Info, status)
+	}
+}
@@ -50,9 +50,21 @@ import (
 //	ANY   - one random item matched
 //	N/Nth - the Nth item matched (or last item if N > matches)
 //
+// If the first item matched is a stack, such as a quiver of arrows, then a
+// plain number, without a suffix such as ST, ND, RD or TH, is used as a count
+// of the number of items wanted from the stack instead. For example "5 ARROW"
+// matches the first stack of arrows, see MatchCounts. Nth can still be used
+// to select a specific stack, for example "2ND ARROW".
+//
 // NOTE: For performance reasons, if a thing being searched is considered
 // crowded then we don't include everyone in the crowd in the search.
 //
+// NOTE: If a thing being searched is a dark location that is not lit, see the
+// lit function, then only narrative items and the actor performing the command
+// can be found. Hidden items can only be found if the actor performing the
+// command has found them using SEARCH. Things that cannot be found can still
+// be matched directly by UID, for example by scripting.
+//
 // TODO(diddymus): Add 'see also' pointing to docs/ files.
 //
 // BUG(diddymus): Nth does not care what the suffix is, 2nd and 2rd are both
@@ -61,7 +73,24 @@ import (
 // BUG(diddyus): Does not support ranges yet. For example 'which 2-4 ball' for
 // the 2nd, 3rd and 4th balls.
 func Match(words []string, where ...*Thing) (results []string) {
-	results, _ = match(words, where, false)
+	results, _, _ = match(words, where, false)
+	return
+}
+
+// MatchCounts works in the same way as Match except that it also returns the
+// number of items wanted from stacks that were matched with a count, indexed
+// by the UID of the stack. For example, if we have a stack of 30 arrows then
+// in the following:
+//
+//	MatchCounts([]string{"5", "ARROWS"}, s.actor)
+//
+// Might return:
+//
+//	[]string{"#UID-10A"} and map[string]int64{"#UID-10A": 5}
+//
+// Stacks matched without a count are not included in the returned counts.
+func MatchCounts(words []string, where ...*Thing) (results []string, counts map[string]int64) {
+	results, _, counts = match(words, where, false)
 	return
 }
 
@@ -84,25 +113,61 @@ func Match(words []string, where ...*Thing) (results []string) {
 //
 //	[]string{"#UID-10A", "#UID-10E"} and []string{"RED", "BALL"}
 func LimitedMatch(words []string, where ...*Thing) (results, remaining []string) {
-	return match(words, where, true)
+	results, remaining, _ = match(words, where, true)
+	return
+}
+
+// self returns the actor performing the current command, as identified by the
+// "SELF" dynamic alias, from the passed things or their inventories. If the
+// actor cannot be found nil is returned.
+func self(where []*Thing) *Thing {
+	for _, inv := range where {
+		if inv.As[DynamicAlias] == "SELF" {
+			return inv
+		}
+		for _, inv := range []Things{inv.Who, inv.In} {
+			for _, item := range inv {
+				if item.As[DynamicAlias] == "SELF" {
+					return item
+				}
+			}
+		}
+	}
+	return nil
 }
 
 // match implements the functionality for Match and LimitedMatch.
-func match(words []string, where []*Thing, oneShot bool) ([]string, []string) {
+func match(words []string, where []*Thing, oneShot bool) ([]string, []string, map[string]int64) {
 
 	data := []*Thing{}
+	unseen := map[*Thing]bool{}
+	actor := self(where)
 	for _, inv := range where {
 		// For performance don't include all of the players if there is a crowd.
 		if len(inv.Who) < cfg.crowdSize {
 			data = append(data, inv.Who.Sort()...)
 		}
 		data = append(data, inv.In.Sort()...)
+
+		// In the dark only narratives, such as doors, and ourself can be found.
+		// Hidden items can only be found if we found them using SEARCH.
+		dark := !lit(inv)
+		for _, inv := range []Things{inv.Who, inv.In} {
+			for _, item := range inv {
+				switch {
+				case item == actor:
+				case dark && item.Is&Narrative == 0, hiddenItem(actor, item):
+					unseen[item] = true
+				}
+			}
+		}
 	}
 
 	var (
 		matches           = make([]*Thing, 0, len(data))
 		subset            = make([]*Thing, 0, len(data))
 		results           = make([]string, 0, len(data))
+		counts            = map[string]int64{}
 		alias, bound, nbr string
 		pos, l            int
 	)
@@ -112,6 +177,9 @@ func match(words []string, where []*Thing, oneShot bool) ([]string, []string) {
 		// Filter items by alias
 		alias, matches = words[pos], matches[:0]
 		for _, item := range data {
+			if unseen[item] && item.As[UID] != alias {
+				continue
+			}
 			if item.As[DynamicAlias] == alias {
 				matches = append(matches, item)
 				continue
@@ -179,10 +247,17 @@ func match(words []string, where []*Thing, oneShot bool) ([]string, []string) {
 			pos--
 		case '1' <= words[pos-1][0] && words[pos-1][0] <= '9':
 			nbr = words[pos-1]
+			ordinal := false
 			if l := len(nbr); l > 2 && strings.Contains("STNDRDTH", nbr[l-2:]) {
-				nbr = nbr[:l-2]
+				nbr, ordinal = nbr[:l-2], true
 			}
 			if cnt, err := strconv.Atoi(nbr); err == nil {
+				if !ordinal && matches[0].Int[Quantity] > 0 {
+					results = append(results, matches[0].As[UID])
+					counts[matches[0].As[UID]] = int64(cnt)
+					pos--
+					break
+				}
 				if cnt > len(matches) {
 					cnt = len(matches)
 				}
@@ -219,7 +294,7 @@ func match(words []string, where []*Thing, oneShot bool) ([]string, []string) {
 	if pos < 0 {
 		pos = 0
 	}
-	return results[:x], words[:pos]
+	return results[:x], words[:pos], counts
 }
 
 // StripMatch removes the leading stopwords, modifiers, qualifiers and alias
@@ -150,16 +150,57 @@ func min(a, b int) int {
 	return b
 }
 
+// typeMessages are the default combat messages for damage types. They are
+// used for wielded items, and players and mobiles, that have a DAMAGETYPE but
+// no ONCOMBAT messages of their own.
+var typeMessages = map[string][]string{
+	"SLASH": {
+		"[%A] slash[/es] at [%d] drawing blood.",
+		"[%A] slice[/s] into [%d].",
+		"[%A] cut[/s] [%d] with a wide sweep.",
+	},
+	"PIERCE": {
+		"[%A] stab[/s] [%d].",
+		"[%A] lunge[/s] at [%d], piercing [%d.them].",
+		"[%A] jab[/s] at [%d] drawing blood.",
+	},
+	"BLUNT": {
+		"[%A] club[/s] [%d] with a heavy blow.",
+		"[%A] bash[/es] [%d], bruising [%d.them].",
+		"[%A] smash[/es] into [%d].",
+	},
+	"FIRE": {
+		"[%A] burn[/s] [%d].",
+		"[%A] scorch[/es] [%d] with searing heat.",
+	},
+	"COLD": {
+		"[%A] chill[/s] [%d] to the bone.",
+		"[%A] freeze[/s] [%d] with a numbing blast.",
+	},
+}
+
+// pickMessage returns a random combat message for the actor. Messages for
+// wielded items are used in preference to the actor's natural attacks. If an
+// item or actor has no ONCOMBAT messages, default messages for its damage type
+// are used, if there are any.
 func pickMessage(actor *Thing) string {
 	var msgs []string
 	for _, item := range actor.In {
-		if item.Is&(Wielding) != 0 && len(item.Any[OnCombat]) != 0 {
+		if item.Is&(Wielding) == 0 {
+			continue
+		}
+		if len(item.Any[OnCombat]) != 0 {
 			msgs = append(msgs, item.Any[OnCombat]...)
+		} else {
+			msgs = append(msgs, typeMessages[item.As[DamageType]]...)
 		}
 	}
 	if len(msgs) == 0 {
 		msgs = actor.Any[OnCombat]
 	}
+	if len(msgs) == 0 {
+		msgs = typeMessages[actor.As[DamageType]]
+	}
 	if len(msgs) == 0 {
 		return "[%A] hit[/s] [%d] wounding [%d.them]."
 	}
@@ -0,0 +1,222 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package core
+
+import (
+	"strconv"
+
+	"code.wolfmud.org/WolfMUD.git/text"
+)
+
+// shopkeeper returns the first shopkeeper at the actor's location that is
+// not busy fighting. Returns nil, after notifying the actor, if there is no
+// shopkeeper available.
+func (s *state) shopkeeper() *Thing {
+	busy := false
+	for _, npc := range s.actor.Ref[Where].In.Sort() {
+		if npc.Is&Shop != Shop {
+			continue
+		}
+		if len(npc.Any[Opponents]) > 0 {
+			busy = true
+			continue
+		}
+		return npc
+	}
+	if busy {
+		s.Msg(s.actor, text.Bad, "The shopkeeper is too busy fighting to serve you.")
+	} else {
+		s.Msg(s.actor, text.Bad, "There is no shopkeeper here.")
+	}
+	return nil
+}
+
+// coins returns the passed amount as a string of coins, e.g. "1 coin" or
+// "3 coins".
+func coins(amount int64) string {
+	if amount == 1 {
+		return "1 coin"
+	}
+	return strconv.FormatInt(amount, 10) + " coins"
+}
+
+// forSale returns true if the passed item, in a shopkeeper's inventory, can
+// be bought, otherwise false. Items being used by the shopkeeper and items
+// without a value are not for sale.
+func forSale(what *Thing) bool {
+	return what.Int[Value] > 0 && what.Is&Using == 0 && what.Is&Narrative == 0
+}
+
+// sellPrice returns the price a shopkeeper charges for an item, or for count
+// items from a stack. A count of 0 is for all of the items in a stack. The
+// minimum price for an item with a value is 1 coin.
+func sellPrice(shop, what *Thing, count int64) int64 {
+	price := what.Int[Value] * counted(what, count) * shop.Int[ShopSell] / 100
+	if price < 1 {
+		price = 1
+	}
+	return price
+}
+
+// buyPrice returns the price a shopkeeper will pay for an item, or for count
+// items from a stack. A count of 0 is for all of the items in a stack. A price
+// of 0 means the shopkeeper will not buy the item.
+func buyPrice(shop, what *Thing, count int64) int64 {
+	return what.Int[Value] * counted(what, count) * shop.Int[ShopBuy] / 100
+}
+
+// List shows the items a shopkeeper has for sale and their prices.
+func (s *state) List() {
+	shop := s.shopkeeper()
+	if shop == nil {
+		return
+	}
+
+	stock := []*Thing{}
+	for _, what := range shop.In.Sort() {
+		if forSale(what) {
+			stock = append(stock, what)
+		}
+	}
+
+	if len(stock) == 0 {
+		s.Msg(s.actor, text.Info, shop.As[UTheName], " has nothing for sale at the moment.")
+		return
+	}
+
+	s.Msg(s.actor, shop.As[UTheName], " has for sale:")
+	for _, what := range stock {
+		s.Msg(s.actor, "  ", what.As[Name], " - ", coins(sellPrice(shop, what, 0)))
+	}
+	s.Msg(s.actor, "You have ", coins(s.actor.Int[Money]), ".")
+}
+
+// Buy purchases items from a shopkeeper. If the item bought is spawnable the
+// actor receives a copy and the shopkeeper restocks when the item resets.
+func (s *state) Buy() {
+
+	if len(s.word) == 0 {
+		s.Msg(s.actor, text.Info, "You go to buy... something?")
+		return
+	}
+
+	shop := s.shopkeeper()
+	if shop == nil {
+		return
+	}
+
+	notify := len(s.actor.Ref[Where].Who) < cfg.crowdSize
+
+	uids, counts := MatchCounts(s.word, shop)
+	for _, uid := range uids {
+		what := shop.In[uid]
+		switch {
+		case what == nil:
+			s.Msg(s.actor, text.Bad, shop.As[UTheName], " has no '", uid, "' for sale.")
+		case !forSale(what):
+			s.Msg(s.actor, text.Bad, shop.As[UTheName], " will not sell you ", what.As[TheName], ".")
+		case sellPrice(shop, what, counts[uid]) > s.actor.Int[Money]:
+			s.Msg(s.actor, text.Bad, "You can't afford ", what.As[TheName],
+				", it costs ", coins(sellPrice(shop, what, counts[uid])), ".")
+		case !fits(what, s.actor, counts[uid]):
+			s.tooHeavy(what, s.actor, counts[uid])
+		default:
+			price := sellPrice(shop, what, counts[uid])
+			s.actor.Int[Money] -= price
+			what = unstack(what, counts[uid])
+			delete(shop.In, what.As[UID])
+			what = what.Spawn()
+			s.actor.In[what.As[UID]] = what
+			what.Ref[Where] = s.actor
+			what.As[DynamicQualifier] = "MY"
+			s.Msg(s.actor, text.Good, "You buy ", what.As[TheName], " from ",
+				shop.As[TheName], " for ", coins(price), ".")
+			if notify {
+				s.Msg(s.actor.Ref[Where], text.Info, s.actor.As[UTheName], " buys ",
+					what.As[Name], " from ", shop.As[TheName], ".")
+			}
+			stack(what)
+		}
+	}
+}
+
+// Sell sells items to a shopkeeper. Items sold are junked.
+func (s *state) Sell() {
+
+	if len(s.word) == 0 {
+		s.Msg(s.actor, text.Info, "You go to sell... something?")
+		return
+	}
+
+	shop := s.shopkeeper()
+	if shop == nil {
+		return
+	}
+
+	notify := len(s.actor.Ref[Where].Who) < cfg.crowdSize
+
+	uids, counts := MatchCounts(s.word, s.actor)
+	for _, uid := range uids {
+		what := s.actor.In[uid]
+		switch {
+		case what == nil:
+			s.Msg(s.actor, text.Bad, "You do not have any '", uid, "' to sell.")
+		case what.As[VetoDrop] != "":
+			s.Msg(s.actor, text.Bad, what.As[VetoDrop])
+		case what.As[VetoJunk] != "":
+			s.Msg(s.actor, text.Bad, what.As[VetoJunk])
+		case what.Is&Using != 0:
+			s.Msg(s.actor, text.Bad, "You can't sell ", what.As[TheName], " while using it.")
+		case len(what.In) > 0:
+			s.Msg(s.actor, text.Bad, "You need to empty ", what.As[TheName], " before selling it.")
+		case buyPrice(shop, what, counts[uid]) < 1:
+			s.Msg(s.actor, text.Bad, shop.As[UTheName], " is not interested in ", what.As[TheName], ".")
+		default:
+			price := buyPrice(shop, what, counts[uid])
+			s.actor.Int[Money] += price
+			what = unstack(what, counts[uid])
+			s.Msg(s.actor, text.Good, "You sell ", what.As[TheName], " to ",
+				shop.As[TheName], " for ", coins(price), ".")
+			if notify {
+				s.Msg(s.actor.Ref[Where], text.Info, s.actor.As[UTheName], " sells ",
+					what.As[Name], " to ", shop.As[TheName], ".")
+			}
+			what.Junk()
+		}
+	}
+}
+
+// Value asks a shopkeeper how much they would pay for items.
+func (s *state) Value() {
+
+	if len(s.word) == 0 {
+		s.Msg(s.actor, text.Info, "You go to value... something?")
+		return
+	}
+
+	shop := s.shopkeeper()
+	if shop == nil {
+		return
+	}
+
+	uids, counts := MatchCounts(s.word, s.actor)
+	for _, uid := range uids {
+		what := s.actor.In[uid]
+		switch {
+		case what == nil:
+			s.Msg(s.actor, text.Bad, "You do not have any '", uid, "' to value.")
+		case buyPrice(shop, what, counts[uid]) < 1:
+			s.Msg(s.actor, text.Info, shop.As[UTheName], " is not interested in ", what.As[TheName], ".")
+		default:
+			name := what.As[TheName]
+			if n := counted(what, counts[uid]); n < quantity(what) {
+				name = strconv.FormatInt(n, 10) + " of " + name
+			}
+			s.Msg(s.actor, text.Info, shop.As[UTheName], " would pay ",
+				coins(buyPrice(shop, what, counts[uid])), " for ", name, ".")
+		}
+	}
+}
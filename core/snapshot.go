@@ -0,0 +1,372 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package core
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.wolfmud.org/WolfMUD.git/recordjar"
+	"code.wolfmud.org/WolfMUD.git/recordjar/decode"
+	"code.wolfmud.org/WolfMUD.git/recordjar/encode"
+	"code.wolfmud.org/WolfMUD.git/text"
+)
+
+// SnapshotOrdering is the preferred ordering of fields when writing a
+// snapshot returned by Snapshot.
+var SnapshotOrdering = []string{
+	"Created", "Path", "Spawn", "Copy", "Spawnable", "Where", "Out", "Open",
+	"Locked", "Lit", "Hidden", "Health", "Quantity", "Events", "Suspended",
+}
+
+// snapshotEvents are the events recorded in a snapshot. Combat events are not
+// recorded as opponents are not restored.
+var snapshotEvents = []eventKey{
+	Action, Burn, Cleanup, Health, Reset, Trigger, Wander,
+}
+
+// Snapshot returns the current state of the world as a recordjar. Snapshot
+// should be called while holding the BWL.
+//
+// Things are identified using the snapshot path recorded when they were first
+// put into the world. For example "ZINARA:L1/ZINARA:O1" identifies the item
+// O1 in location L1. Spawned copies have no path of their own, they are
+// identified by the path of the item they were spawned from and a snapshot
+// specific copy ID of the form "#n".
+//
+// For each Thing the record includes where the Thing is and if it is out of
+// play, if a spawned copy is itself spawnable, the open or closed, locked or
+// unlocked, lit or unlit and hidden state, current health, the quantity of a
+// stack and in-flight or suspended events along with the time remaining
+// before they are due. Players and their inventories are not included as they
+// are saved separately. Things without a snapshot path, such as corpses, are
+// also not included.
+func Snapshot() recordjar.Jar {
+
+	things := []*Thing{}
+	ids := map[*Thing]string{}
+	seen := map[*Thing]bool{}
+
+	var walk func(where *Thing)
+	walk = func(where *Thing) {
+		for _, inv := range []Things{where.In, where.Out} {
+			for _, item := range inv.Sort() {
+				if item.Ref[Where] != where || seen[item] {
+					continue
+				}
+				seen[item] = true
+				things = append(things, item)
+				if item.As[Path] == "" && item.As[Prototype] != "" {
+					ids[item] = "#" + strconv.Itoa(len(ids)+1)
+				}
+				walk(item)
+			}
+		}
+	}
+
+	locs := make([]*Thing, 0, len(World))
+	for _, loc := range World {
+		locs = append(locs, loc)
+	}
+	sort.Slice(locs, func(i, j int) bool {
+		return locs[i].As[Path] < locs[j].As[Path]
+	})
+	for _, loc := range locs {
+		things = append(things, loc)
+		walk(loc)
+	}
+
+	id := func(t *Thing) string {
+		if t.As[Path] != "" {
+			return t.As[Path]
+		}
+		return ids[t]
+	}
+
+	jar := recordjar.Jar{
+		recordjar.Record{"CREATED": encode.DateTime(time.Now())},
+	}
+
+	now := time.Now().UnixNano()
+	for _, t := range things {
+		r := recordjar.Record{}
+
+		switch {
+		case t.As[Path] != "":
+			r["PATH"] = encode.String(t.As[Path])
+		case ids[t] != "":
+			r["SPAWN"] = encode.String(t.As[Prototype])
+			r["COPY"] = encode.String(ids[t])
+			if t.Is&Spawnable == Spawnable {
+				r["SPAWNABLE"] = encode.Boolean(true)
+			}
+		default:
+			continue
+		}
+
+		if where := t.Ref[Where]; where != nil && t.Is&Location != Location {
+			switch {
+			case id(where) != "":
+				r["WHERE"] = encode.String(id(where))
+			case ids[t] != "":
+				continue // Copy in something we can't restore
+			}
+			if where.Out[t.As[UID]] == t {
+				r["OUT"] = encode.Boolean(true)
+			}
+		}
+
+		if t.As[Blocker] != "" || t.Is&(Open|_Open) != 0 {
+			r["OPEN"] = encode.Boolean(t.Is&Open == Open)
+		}
+
+		if len(t.Any[LockKeys]) > 0 {
+			r["LOCKED"] = encode.Boolean(t.Is&Locked == Locked)
+		}
+
+		if t.Is&Light == Light {
+			r["LIT"] = encode.Boolean(t.Is&Lit == Lit)
+		}
+
+		if t.Is&_Hidden == _Hidden {
+			r["HIDDEN"] = encode.Boolean(t.Is&Hidden == Hidden)
+		}
+
+		if t.Int[HealthMaximum] > 0 {
+			r["HEALTH"] = encode.Integer(int(t.Int[HealthCurrent]))
+		}
+
+		if t.Int[Quantity] > 0 {
+			r["QUANTITY"] = encode.Integer(int(t.Int[Quantity]))
+		}
+
+		events, suspended := map[string]string{}, map[string]string{}
+		for _, event := range snapshotEvents {
+			idx := intKey(event)
+			switch {
+			case t.Event[event] != nil:
+				dueIn := time.Duration(t.Int[idx+DueAtOffset] - now)
+				if dueIn < 0 {
+					dueIn = 0
+				}
+				events[eventNames[event]] = string(encode.Duration(dueIn))
+			case t.Int[idx+DueInOffset] > 0:
+				dueIn := time.Duration(t.Int[idx+DueInOffset])
+				suspended[eventNames[event]] = string(encode.Duration(dueIn))
+			}
+		}
+		if len(events) > 0 {
+			r["EVENTS"] = encode.PairList(events, '→')
+		}
+		if len(suspended) > 0 {
+			r["SUSPENDED"] = encode.PairList(suspended, '→')
+		}
+
+		// Skip locations with nothing to restore
+		if t.Is&Location == Location && len(r) == 1 {
+			continue
+		}
+
+		jar = append(jar, r)
+	}
+
+	return jar
+}
+
+// Restore applies a snapshot, as returned by Snapshot, to the world. Restore
+// should be called while holding the BWL, after the world has been loaded and
+// before any players enter the world.
+//
+// Spawned copies are recreated from the item they were spawned from. Things
+// are then moved to where they were when the snapshot was taken and their
+// open or closed, locked or unlocked, lit or unlit and hidden state, current
+// health, the quantity of a stack and events restored. Things in the world
+// that are not in the snapshot, for example if a zone file has been changed,
+// are left as loaded. Records that can no longer be matched to things in the
+// world are logged and ignored.
+func Restore(jar recordjar.Jar) {
+
+	paths := map[string]*Thing{}
+	var walk func(where *Thing)
+	walk = func(where *Thing) {
+		for _, inv := range []Things{where.In, where.Out} {
+			for _, item := range inv {
+				if item.As[Path] != "" && paths[item.As[Path]] == nil {
+					paths[item.As[Path]] = item
+					walk(item)
+				}
+			}
+		}
+	}
+	for _, loc := range World {
+		paths[loc.As[Path]] = loc
+		walk(loc)
+	}
+
+	events := map[string]eventKey{}
+	for _, event := range snapshotEvents {
+		events[strings.ToUpper(eventNames[event])] = event
+	}
+
+	// Recreate spawned copies first so they can be referenced by other records
+	copies := map[string]*Thing{}
+	for _, r := range jar {
+		ref := decode.String(r["SPAWN"])
+		if ref == "" {
+			continue
+		}
+		proto := paths[ref]
+		if proto == nil {
+			log.Printf("Snapshot spawn not found, ignoring: %s", ref)
+			continue
+		}
+		T := proto.Copy(false)
+		T.As[Prototype] = ref
+		delete(T.As, Path)
+		delete(T.Ref, Where)
+		delete(T.Ref, Origin)
+		if len(r["SPAWNABLE"]) == 0 || !decode.Boolean(r["SPAWNABLE"]) {
+			T.Is &^= Spawnable
+			delete(T.Int, ResetAfter)
+			delete(T.Int, ResetJitter)
+			delete(T.Int, ResetDueAt)
+			delete(T.Int, ResetDueIn)
+		}
+		copies[decode.String(r["COPY"])] = T
+	}
+
+	lookup := func(ref string) *Thing {
+		if strings.HasPrefix(ref, "#") {
+			return copies[ref]
+		}
+		return paths[ref]
+	}
+
+	for _, r := range jar {
+
+		var t *Thing
+		switch {
+		case len(r["PATH"]) > 0:
+			if t = paths[decode.String(r["PATH"])]; t == nil {
+				log.Printf("Snapshot path not found, ignoring: %s", r["PATH"])
+				continue
+			}
+		case len(r["COPY"]) > 0:
+			if t = copies[decode.String(r["COPY"])]; t == nil {
+				continue
+			}
+		default:
+			continue
+		}
+
+		ref := decode.String(r["WHERE"])
+		where := lookup(ref)
+		switch {
+		case where == nil && t.As[Path] == "":
+			log.Printf("Snapshot copy has nowhere to go, ignoring: %s", r["SPAWN"])
+			delete(copies, decode.String(r["COPY"]))
+			t.Free()
+			continue
+		case where == nil && ref != "":
+			log.Printf("Snapshot where not found, ignoring: %s", ref)
+		case where == nil, t.Is&Location == Location, t.As[Blocker] != "":
+			// Not moving
+		default:
+			uid := t.As[UID]
+			if old := t.Ref[Where]; old != nil {
+				delete(old.In, uid)
+				delete(old.Out, uid)
+				if old != where {
+					t.Is &^= Using
+					delete(t.As, DynamicQualifier)
+				}
+			}
+			if len(r["OUT"]) > 0 && decode.Boolean(r["OUT"]) {
+				where.Out[uid] = t
+			} else {
+				where.In[uid] = t
+			}
+			t.Ref[Where] = where
+		}
+
+		if len(r["OPEN"]) > 0 {
+			if decode.Boolean(r["OPEN"]) {
+				t.Is |= Open
+			} else {
+				t.Is &^= Open
+			}
+		}
+
+		if len(r["LOCKED"]) > 0 {
+			if decode.Boolean(r["LOCKED"]) {
+				t.Is |= Locked
+			} else {
+				t.Is &^= Locked
+			}
+		}
+
+		if len(r["LIT"]) > 0 {
+			if decode.Boolean(r["LIT"]) {
+				t.Is |= Lit
+			} else {
+				t.Is &^= Lit
+			}
+		}
+
+		if len(r["HIDDEN"]) > 0 {
+			if decode.Boolean(r["HIDDEN"]) {
+				t.Is |= Hidden
+			} else {
+				t.Is &^= Hidden
+			}
+		}
+
+		if len(r["HEALTH"]) > 0 {
+			t.Int[HealthCurrent] = int64(decode.Integer(r["HEALTH"]))
+		}
+
+		if len(r["QUANTITY"]) > 0 {
+			t.Int[Quantity] = int64(decode.Integer(r["QUANTITY"]))
+			t.restack()
+		}
+
+		for _, event := range snapshotEvents {
+			t.Cancel(event)
+		}
+		for name, dueIn := range decode.PairList(r["SUSPENDED"]) {
+			if event, ok := events[name]; ok {
+				t.Int[intKey(event)+DueInOffset] = int64(decode.Duration([]byte(dueIn)))
+			}
+		}
+		for name, dueIn := range decode.PairList(r["EVENTS"]) {
+			if event, ok := events[name]; ok {
+				d := int64(decode.Duration([]byte(dueIn)))
+				if d <= 0 {
+					d = 1 // Overdue, schedule for immediate delivery
+				}
+				t.Int[intKey(event)+DueInOffset] = d
+				t.Schedule(event)
+			}
+		}
+	}
+}
+
+// QuitPlayers removes all players from the world as if they had used the QUIT
+// command, saving them and junking any unique items they are carrying. This
+// keeps the player files in step with a world snapshot taken afterwards, for
+// example when the server is shutting down. QuitPlayers should be called
+// while holding the BWL.
+func QuitPlayers() {
+	for _, player := range Players.Sort() {
+		s := NewState(player)
+		s.Msg(player, text.Bad, "The server is shutting down.")
+		s.parse("$QUIT", withScripting)
+		s.mailman()
+	}
+}
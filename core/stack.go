@@ -0,0 +1,140 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package core
+
+import (
+	"strconv"
+	"strings"
+)
+
+// quantity returns the number of items in a stack, or 1 if what is not a
+// stack.
+func quantity(what *Thing) int64 {
+	if what.Int[Quantity] > 1 {
+		return what.Int[Quantity]
+	}
+	return 1
+}
+
+// counted returns the number of items wanted from the stack what for a count
+// returned by MatchCounts. A count of 0, or a count larger than the number of
+// items in the stack, wants all of the items.
+func counted(what *Thing, count int64) int64 {
+	if count == 0 || count > quantity(what) {
+		return quantity(what)
+	}
+	return count
+}
+
+// plural returns a default plural name for a stack of items, made from the
+// name of a single item. For example "an arrow" becomes "arrows". Stacks with
+// irregular plurals should define a plural name.
+func plural(name string) string {
+	for _, article := range []string{"a ", "an ", "some "} {
+		if strings.HasPrefix(name, article) {
+			name = strings.TrimPrefix(name, article)
+			break
+		}
+	}
+	return name + "s"
+}
+
+// restack updates the names of a stack to reflect the number of items in it.
+// A stack of one item uses the singular name, otherwise the quantity and
+// plural name are used, for example "30 arrows".
+func (t *Thing) restack() {
+	switch t.Int[Quantity] {
+	case 0:
+		// Not a stack
+	case 1:
+		t.nameAs(t.As[Singular])
+	default:
+		name := strconv.FormatInt(t.Int[Quantity], 10) + " " + t.As[Plural]
+		t.As[Name], t.As[UName] = name, name
+		t.As[TheName], t.As[UTheName] = "the "+name, "The "+name
+	}
+}
+
+// stackable returns true if what is a stack that can be merged with another
+// stack, otherwise false. Unique and spawnable stacks are never merged as
+// they need to reset, stacks being used are not merged either.
+func stackable(what *Thing) bool {
+	return what.Int[Quantity] > 0 && what.Ref[Origin] == nil &&
+		what.Is&(Spawnable|Using) == 0
+}
+
+// sameStack returns true if a and b are stacks of the same items, otherwise
+// false. Stacks of drink containers filled differently are not the same.
+func sameStack(a, b *Thing) bool {
+	return a.As[Singular] == b.As[Singular] &&
+		a.As[Plural] == b.As[Plural] &&
+		a.As[Description] == b.As[Description] &&
+		a.Int[Value] == b.Int[Value] &&
+		a.Int[Weight] == b.Int[Weight] &&
+		a.Int[Sips] == b.Int[Sips] &&
+		a.Int[Hydration] == b.Int[Hydration] &&
+		a.Int[Heal] == b.Int[Heal]
+}
+
+// stack merges what with another stack of the same items in the same
+// inventory, returning the merged stack. If there is no stack to merge with
+// what is returned unchanged. When merged what is freed.
+func stack(what *Thing) *Thing {
+	where := what.Ref[Where]
+	if where == nil || !stackable(what) {
+		return what
+	}
+	for _, other := range where.In.Sort() {
+		if other == what || !stackable(other) || !sameStack(what, other) {
+			continue
+		}
+		other.Int[Quantity] += what.Int[Quantity]
+		other.restack()
+		delete(where.In, what.As[UID])
+		what.Free()
+		return other
+	}
+	return what
+}
+
+// unstack takes count items from the stack what, returning a new stack of
+// count items. The new stack is put into the same inventory as what and the
+// rest of the items remain in the original stack. If count is 0, or there are
+// not more than count items in the stack, what is returned unchanged.
+//
+// The new stack is never unique or spawnable. If what is spawnable it stays
+// where it is with fewer items, and a reset is scheduled for it to restore
+// its quantity. This avoids leaving behind a copy of the remaining items when
+// the spawnable stack resets.
+func unstack(what *Thing, count int64) *Thing {
+	if count == 0 || what.Int[Quantity] <= count {
+		return what
+	}
+
+	T := what.Copy(false)
+	if T.As[Path] != "" {
+		T.As[Prototype] = T.As[Path]
+		delete(T.As, Path)
+	}
+	T.Is &^= Spawnable
+	T.Cancel(Reset)
+	delete(T.Ref, Origin)
+	delete(T.Int, ResetAfter)
+	delete(T.Int, ResetJitter)
+	delete(T.Int, ResetDueAt)
+	delete(T.Int, ResetDueIn)
+
+	T.Int[Quantity] = count
+	what.Int[Quantity] -= count
+	T.restack()
+	what.restack()
+	what.Ref[Where].In[T.As[UID]] = T
+
+	if what.Is&Spawnable == Spawnable && what.Event[Reset] == nil {
+		what.Schedule(Reset)
+	}
+	return T
+}
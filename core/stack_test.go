@@ -0,0 +1,272 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+// newStack returns a stack of quantity arrows in the inventory of where.
+func newStack(where *Thing, quantity int64) *Thing {
+	t := NewThing()
+	t.As[Singular] = "an arrow"
+	t.As[Plural] = "arrows"
+	t.As[Description] = "A sharp arrow."
+	t.Int[Weight] = 1
+	t.Int[Value] = 2
+	t.Int[Quantity] = quantity
+	t.Int[QuantityReset] = quantity
+	t.restack()
+	t.Ref[Where] = where
+	where.In[t.As[UID]] = t
+	return t
+}
+
+// total returns the total number of items in all of the stacks in where.
+func total(where *Thing) (n int64) {
+	for _, item := range where.In {
+		n += quantity(item)
+	}
+	return
+}
+
+func TestQuantity(t *testing.T) {
+	where := NewThing()
+	for _, test := range []struct {
+		quantity int64
+		count    int64
+		want     int64
+		counted  int64
+	}{
+		{0, 0, 1, 1},
+		{1, 0, 1, 1},
+		{5, 0, 5, 5},
+		{5, 2, 5, 2},
+		{5, 5, 5, 5},
+		{5, 9, 5, 5},
+	} {
+		what := newStack(where, test.quantity)
+		if have := quantity(what); have != test.want {
+			t.Errorf("quantity(%d): have %d, want %d", test.quantity, have, test.want)
+		}
+		if have := counted(what, test.count); have != test.counted {
+			t.Errorf("counted(%d, %d): have %d, want %d", test.quantity, test.count, have, test.counted)
+		}
+	}
+}
+
+func TestPlural(t *testing.T) {
+	for _, test := range []struct{ name, want string }{
+		{"an arrow", "arrows"},
+		{"a coin", "coins"},
+		{"some nail", "nails"},
+		{"apple", "apples"},
+	} {
+		if have := plural(test.name); have != test.want {
+			t.Errorf("plural(%q): have %q, want %q", test.name, have, test.want)
+		}
+	}
+}
+
+func TestUnstack(t *testing.T) {
+	where := NewThing()
+	what := newStack(where, 5)
+
+	for _, count := range []int64{0, 5, 9} {
+		if have := unstack(what, count); have != what {
+			t.Errorf("unstack %d: have new stack, want original", count)
+		}
+	}
+
+	T := unstack(what, 2)
+	switch {
+	case T == what:
+		t.Fatalf("unstack 2: have original, want new stack")
+	case T.Int[Quantity] != 2 || T.As[Name] != "2 arrows":
+		t.Errorf("new stack: have %d %q, want 2 %q", T.Int[Quantity], T.As[Name], "2 arrows")
+	case what.Int[Quantity] != 3 || what.As[Name] != "3 arrows":
+		t.Errorf("original: have %d %q, want 3 %q", what.Int[Quantity], what.As[Name], "3 arrows")
+	case where.In[T.As[UID]] != T || T.Ref[Where] != where:
+		t.Errorf("new stack not in original's inventory")
+	case total(where) != 5:
+		t.Errorf("total: have %d, want 5", total(where))
+	}
+
+	if have := unstack(what, 2); have.Int[Quantity] != 2 || what.Int[Quantity] != 1 {
+		t.Errorf("unstack 2 more: have %d and %d, want 2 and 1",
+			have.Int[Quantity], what.Int[Quantity])
+	}
+	if what.As[Name] != "an arrow" {
+		t.Errorf("single item name: have %q, want %q", what.As[Name], "an arrow")
+	}
+}
+
+// TestUnstackReset checks taking part of a spawnable stack, for example from
+// a shopkeeper, does not create extra items when the stack resets.
+func TestUnstackReset(t *testing.T) {
+	shop, buyer := NewThing(), NewThing()
+	what := newStack(shop, 5)
+	what.Is |= Spawnable
+	what.Int[ResetAfter] = int64(time.Hour)
+	defer what.Cancel(Reset)
+
+	take := func(count int64) *Thing {
+		T := unstack(what, count)
+		delete(shop.In, T.As[UID])
+		T.Ref[Where] = buyer
+		buyer.In[T.As[UID]] = T
+		return T
+	}
+
+	T1 := take(2)
+	switch {
+	case shop.In[what.As[UID]] != what || what.Int[Quantity] != 3:
+		t.Errorf("original: have %d in shop: %t, want 3 in shop: true",
+			what.Int[Quantity], shop.In[what.As[UID]] == what)
+	case what.Is&Spawnable != Spawnable:
+		t.Errorf("original no longer spawnable")
+	case what.Event[Reset] == nil:
+		t.Errorf("original reset not scheduled")
+	case T1.Is&Spawnable == Spawnable:
+		t.Errorf("new stack is spawnable")
+	case T1.Event[Reset] != nil || T1.Int[ResetAfter] != 0:
+		t.Errorf("new stack has reset")
+	}
+
+	// Take more with the reset pending
+	T2 := take(1)
+	if T2.Event[Reset] != nil || T2.Int[ResetAfter] != 0 {
+		t.Errorf("second new stack has reset")
+	}
+
+	NewState(what).Reset()
+
+	if have := total(shop); have != 5 {
+		t.Errorf("shop after reset: have %d, want 5", have)
+	}
+	if have := total(buyer); have != 3 {
+		t.Errorf("buyer after reset: have %d, want 3", have)
+	}
+	if what.As[Name] != "5 arrows" {
+		t.Errorf("name after reset: have %q, want %q", what.As[Name], "5 arrows")
+	}
+}
+
+func TestStack(t *testing.T) {
+	where := NewThing()
+
+	a, b := newStack(where, 3), newStack(where, 4)
+	merged := stack(b)
+	switch {
+	case merged != a:
+		t.Errorf("stack: have %s, want %s", merged.As[UID], a.As[UID])
+	case a.Int[Quantity] != 7 || a.As[Name] != "7 arrows":
+		t.Errorf("merged: have %d %q, want 7 %q", a.Int[Quantity], a.As[Name], "7 arrows")
+	case where.In[b.As[UID]] != nil:
+		t.Errorf("merged stack still in inventory")
+	case len(where.In) != 1:
+		t.Errorf("inventory: have %d items, want 1", len(where.In))
+	}
+
+	// Unstack and merge back together
+	T := unstack(a, 2)
+	if merged := stack(T); merged != a || a.Int[Quantity] != 7 || len(where.In) != 1 {
+		t.Errorf("restack: have %d in %d stacks, want 7 in 1", total(where), len(where.In))
+	}
+
+	// Stacks that should not be merged
+	for _, test := range []struct {
+		name   string
+		modify func(*Thing)
+	}{
+		{"different items", func(t *Thing) { t.As[Singular] = "a bolt" }},
+		{"different value", func(t *Thing) { t.Int[Value] = 3 }},
+		{"differently filled", func(t *Thing) { t.Int[Sips] = 2 }},
+		{"spawnable", func(t *Thing) { t.Is |= Spawnable }},
+		{"being used", func(t *Thing) { t.Is |= Wielding }},
+		{"unique", func(t *Thing) { t.Ref[Origin] = t }},
+		{"not a stack", func(t *Thing) { delete(t.Int, Quantity) }},
+	} {
+		where := NewThing()
+		a, b := newStack(where, 3), newStack(where, 4)
+		test.modify(b)
+		if merged := stack(b); merged != b || a.Int[Quantity] != 3 || len(where.In) != 2 {
+			t.Errorf("%s: merged", test.name)
+		}
+	}
+}
+
+// newSkins returns a stack of quantity waterskins, each holding sips drinks,
+// being carried by a player at a location.
+func newSkins(quantity, sips int64) (player, skins *Thing) {
+	where := NewThing()
+	player = NewThing()
+	player.Is |= Player
+	player.Ref[Where] = where
+	where.Who[player.As[UID]] = player
+
+	skins = newStack(player, quantity)
+	skins.As[Singular] = "a waterskin"
+	skins.As[Plural] = "waterskins"
+	skins.Any[Alias] = []string{"WATERSKIN"}
+	skins.Is |= Drinkable
+	skins.Int[SipsMaximum] = 3
+	skins.Int[Sips] = sips
+	skins.restack()
+	return
+}
+
+// sips returns the number of waterskins in the player's inventory by the
+// number of drinks they hold.
+func sips(player *Thing) map[int64]int64 {
+	n := map[int64]int64{}
+	for _, item := range player.In {
+		n[item.Int[Sips]] += quantity(item)
+	}
+	return n
+}
+
+// TestDrinkStack checks drinking from a stack of drink containers only takes
+// a drink from one of the containers.
+func TestDrinkStack(t *testing.T) {
+	player, skins := newSkins(3, 3)
+
+	s := NewState(player)
+	s.word = []string{"WATERSKIN"}
+	s.Drink()
+
+	if have := sips(player); have[3] != 2 || have[2] != 1 || len(have) != 2 {
+		t.Errorf("waterskins by drinks held: have %v, want map[2:1 3:2]", have)
+	}
+	if skins.Int[Quantity] != 2 || skins.Int[Sips] != 3 {
+		t.Errorf("original: have %d holding %d, want 2 holding 3",
+			skins.Int[Quantity], skins.Int[Sips])
+	}
+}
+
+// TestFillStack checks filling a stack of drink containers only fills one of
+// the containers.
+func TestFillStack(t *testing.T) {
+	player, skins := newSkins(3, 0)
+
+	fountain := NewThing()
+	fountain.Is |= Drinkable
+	fountain.Int[Hydration] = 5
+	player.Ref[Where].In[fountain.As[UID]] = fountain
+
+	s := NewState(player)
+	s.word = []string{"WATERSKIN"}
+	s.Fill()
+
+	if have := sips(player); have[0] != 2 || have[3] != 1 || len(have) != 2 {
+		t.Errorf("waterskins by drinks held: have %v, want map[0:2 3:1]", have)
+	}
+	if skins.Int[Quantity] != 2 || skins.Int[Sips] != 0 {
+		t.Errorf("original: have %d holding %d, want 2 holding 0",
+			skins.Int[Quantity], skins.Int[Sips])
+	}
+}
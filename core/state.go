@@ -28,10 +28,16 @@ var (
 )
 
 type pkgConfig struct {
-	crowdSize   int // Represents minimum number of players considered a crowd
-	debugThings bool
-	debugEvents bool
-	playerPath  string
+	crowdSize     int // Represents minimum number of players considered a crowd
+	debugThings   bool
+	debugEvents   bool
+	playerPath    string
+	playerDrop    string // Items player drops on death: ALL, NONE or RANDOM
+	corpseCleanup int64  // Clean-up delay for corpses holding items
+	pvpPolicy     string // Default PvP policy: FORBIDDEN, CONSENSUAL or FREE
+	pvpCooldown   int64  // Minimum period between changes to a player's PvP flag
+	combatLog     string // Path to combat log, empty if combat log disabled
+	hungerRate    int64  // How often players get hungrier, 0 if disabled
 }
 
 // cfg setup by Config and should be treated as immutable and not changed.
@@ -40,13 +46,36 @@ var cfg pkgConfig
 // Config sets up package configuration for settings that can't be constants.
 // It should be called by main, only once, before anything else starts. Once
 // the configuration is set it should be treated as immutable an not changed.
-func Config(c config.Config) {
+// An error is returned if a setting has a value the core package does not
+// know about.
+func Config(c config.Config) error {
+	if !intersects([]string{c.Death.PlayerDrop}, playerDrops) {
+		return fmt.Errorf("invalid Death.PlayerDrop %q, expected %s",
+			c.Death.PlayerDrop, strings.Join(playerDrops, ", "))
+	}
+	if !ValidPvPPolicy(c.PvP.Policy) {
+		return fmt.Errorf("invalid PvP.Policy %q, expected %s",
+			c.PvP.Policy, strings.Join(pvpPolicies, ", "))
+	}
+
 	cfg = pkgConfig{
-		crowdSize:   c.Inventory.CrowdSize,
-		debugThings: c.Debug.Things,
-		debugEvents: c.Debug.Events,
-		playerPath:  filepath.Join(c.Server.DataPath, "players"),
+		crowdSize:     c.Inventory.CrowdSize,
+		debugThings:   c.Debug.Things,
+		debugEvents:   c.Debug.Events,
+		playerPath:    filepath.Join(c.Server.DataPath, "players"),
+		playerDrop:    c.Death.PlayerDrop,
+		corpseCleanup: c.Death.CorpseCleanup.Nanoseconds(),
+		pvpPolicy:     c.PvP.Policy,
+		pvpCooldown:   c.PvP.Cooldown.Nanoseconds(),
+		hungerRate:    c.Hunger.Rate.Nanoseconds(),
 	}
+	if c.Debug.Combat && c.Debug.CombatLog != "" {
+		cfg.combatLog = c.Debug.CombatLog
+		if !filepath.IsAbs(cfg.combatLog) {
+			cfg.combatLog = filepath.Join(c.Server.DataPath, cfg.combatLog)
+		}
+	}
+	return nil
 }
 
 type state struct {
@@ -183,18 +212,34 @@ func (s *state) subparse(input string) {
 // if the alternative actor is killed - the client code will not see the QUIT.
 func (s *state) subparseFor(actor *Thing, input string) {
 
-	// 'mark' messages already sent to the original actor and current location
-	markA := s.buf[s.actor].Len()
-	markL := s.buf[s.actor.Ref[Where]].Len()
+	// 'mark' messages already sent to the original actor and current location.
+	// Buffers may not have been created yet if there are no messages.
+	where := s.actor.Ref[Where]
+	markA, markL := 0, 0
+	if s.buf[s.actor] != nil {
+		markA = s.buf[s.actor].Len()
+	}
+	if s.buf[where] != nil {
+		markL = s.buf[where].Len()
+	}
+
+	// Hide the original actor's "SELF" alias so that it is not matched instead
+	// of, or as well as, the alternative actor.
+	savedDA := s.actor.As[DynamicAlias]
+	delete(s.actor.As, DynamicAlias)
 
 	s2 := &state{actor: actor, buf: s.buf}
 	s2.parse(input, withScripting)
 
+	if s.actor.Is&Freed != Freed {
+		s.actor.As[DynamicAlias] = savedDA
+	}
+
 	// If the original actor already had messages and we have new location
 	// messages, copy the additional location messages to the actor as they will
 	// not be regarded as observers - as they already had specific messages.
-	if markA != 0 && markL != s.buf[s.actor.Ref[Where]].Len() {
-		s.buf[s.actor].WriteString(s.buf[s.actor.Ref[Where]].String()[markL:])
+	if markA != 0 && s.buf[where] != nil && markL != s.buf[where].Len() {
+		s.buf[s.actor].WriteString(s.buf[where].String()[markL:])
 	}
 }
 
@@ -276,10 +321,18 @@ func (s *state) Log(f string, a ...interface{}) {
 	log.Printf(f, a...)
 }
 
-// StatusUpdate updates the player's statistics on the status bar. The
-// messages are sent as priority so that they do not effect the de-spamming of
-// other messages.
+// StatusUpdate updates the player's statistics on the status bar. Mana is
+// only shown for players that have mana. The messages are sent as priority so
+// that they do not effect the de-spamming of other messages.
 func (s state) StatusUpdate(who *Thing) {
+	if who.Int[ManaMaximum] > 0 {
+		mailbox.Send(who.As[UID], true, fmt.Sprintf(
+			"%s Health: %[2]d/%[3]d  Mana: %[4]d/%[5]d\x1b8",
+			who.As[StatusSeq], who.Int[HealthCurrent], who.Int[HealthMaximum],
+			who.Int[ManaCurrent], who.Int[ManaMaximum],
+		))
+		return
+	}
 	mailbox.Send(who.As[UID], true, fmt.Sprintf(
 		"%s Health: %[2]d/%[3]d\x1b8",
 		who.As[StatusSeq], who.Int[HealthCurrent], who.Int[HealthMaximum],
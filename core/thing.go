@@ -12,6 +12,7 @@ import (
 	"math/rand"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -143,6 +144,17 @@ func (t *Thing) InitOnce(parent *Thing) {
 		t.Ref[Origin] = parent
 	}
 
+	// Record the snapshot path for locations and their content. Blockers may be
+	// visited twice, once for each side, so keep the path from the first visit.
+	if t.As[Path] == "" {
+		switch {
+		case t.Is&Location == Location:
+			t.As[Path] = t.As[Ref]
+		case parent != nil && parent.As[Path] != "":
+			t.As[Path] = parent.As[Path] + "/" + t.As[Ref]
+		}
+	}
+
 	for _, item := range t.In {
 		item.InitOnce(t)
 	}
@@ -184,9 +196,18 @@ func (t *Thing) Init() {
 	if t.Int[ActionAfter]+t.Int[ActionJitter] > 0 {
 		t.Schedule(Action)
 	}
+	if t.Int[WanderAfter]+t.Int[WanderJitter] > 0 {
+		t.Schedule(Wander)
+	}
 	if t.Is&NPC == NPC && t.Int[HealthCurrent] < t.Int[HealthMaximum] {
 		t.Schedule(Health)
 	}
+	if t.Is&NPC == NPC && t.Int[ManaCurrent] < t.Int[ManaMaximum] {
+		t.Schedule(Mana)
+	}
+	if t.Is&Lit == Lit {
+		t.Schedule(Burn)
+	}
 }
 
 // decodeInt is a wrapper to recordjar/decode.Integer defaulting the qty to 1
@@ -199,6 +220,46 @@ func decodeInt(qty string) int {
 	return decode.Integer(q)
 }
 
+// exitMessages decodes a keyed string list of per exit messages. The messages
+// are returned indexed by direction, with North at index zero. For example:
+//
+//	E→[%A] squeeze[/s] through the crack in the wall.
+//	: UP→[%A] climb[/s] up the rope.
+//
+// Directions may be given in long or short form. Unknown directions are
+// ignored.
+func exitMessages(data []byte) []string {
+	msgs := make([]string, Down-North+1)
+	for name, msg := range decode.KeyedStringList(data) {
+		if dir, ok := NameToDir[name]; ok {
+			msgs[dir-North] = msg
+		}
+	}
+	return msgs
+}
+
+// nameAs sets the Name, UName, TheName and UTheName of a Thing from the
+// passed name. A leading "a", "an" or "some" in the name is replaced with
+// "the" for TheName and UTheName.
+func (t *Thing) nameAs(name string) {
+	t.As[Name] = name
+	t.As[UName] = text.TitleFirst(name)
+	switch {
+	case strings.HasPrefix(name, "a "):
+		t.As[TheName] = "the" + strings.TrimPrefix(name, "a")
+		t.As[UTheName] = "The" + strings.TrimPrefix(name, "a")
+	case strings.HasPrefix(name, "an "):
+		t.As[TheName] = "the" + strings.TrimPrefix(name, "an")
+		t.As[UTheName] = "The" + strings.TrimPrefix(name, "an")
+	case strings.HasPrefix(name, "some "):
+		t.As[TheName] = "the" + strings.TrimPrefix(name, "some")
+		t.As[UTheName] = "The" + strings.TrimPrefix(name, "some")
+	default:
+		t.As[TheName] = name
+		t.As[UTheName] = t.As[UName]
+	}
+}
+
 // Unmarshal loads data from the passed Record into a Thing.
 //
 // BUG(diddymus): Players will be mistaken for NPCs when they are loaded.
@@ -221,6 +282,16 @@ func (t *Thing) Unmarshal(r recordjar.Record) {
 			if t.Int[ActionAfter]+t.Int[ActionJitter]+t.Int[ActionDueIn] == 0 {
 				t.Int[ActionAfter] = time.Second.Nanoseconds()
 			}
+		case "AGGRESSIVE":
+			t.Is |= Aggressive
+			for k, v := range decode.PairList(r[field]) {
+				switch k {
+				case "ALLOW":
+					t.Any[AggressiveAllow] = strings.Split(strings.ToUpper(v), ",")
+				case "DENY":
+					t.Any[AggressiveDeny] = strings.Split(strings.ToUpper(v), ",")
+				}
+			}
 		case "ALIAS", "ALIASES":
 			a := make(map[string]struct{})
 			q := make(map[string]struct{})
@@ -267,6 +338,8 @@ func (t *Thing) Unmarshal(r recordjar.Record) {
 				}
 			}
 			t.Is |= HasBody
+		case "CAPACITY":
+			t.Int[Capacity] = int64(decode.Integer(r[field]))
 		case "CLEANUP":
 			for k, v := range decode.PairList(r[field]) {
 				b := []byte(v)
@@ -282,6 +355,16 @@ func (t *Thing) Unmarshal(r recordjar.Record) {
 			if t.Int[CleanupAfter]+t.Int[CleanupJitter]+t.Int[CleanupDueIn] == 0 {
 				t.Int[CleanupAfter] = time.Second.Nanoseconds()
 			}
+		case "COOLDOWNS":
+			// Expired cool-downs are dropped, Poof schedules the rest
+			now := time.Now()
+			for ref, v := range decode.PairList(r[field]) {
+				at, err := time.Parse(time.RFC3339, v)
+				if err == nil && at.After(now) {
+					t.Any[Cooldowns] = append(t.Any[Cooldowns],
+						ref+"→"+strconv.FormatInt(at.UnixNano(), 10))
+				}
+			}
 		case "DAMAGE":
 			fixed, random := decode.DoubleInteger(r[field])
 			if fixed != 0 {
@@ -290,6 +373,10 @@ func (t *Thing) Unmarshal(r recordjar.Record) {
 			if random != 0 {
 				t.Int[DamageRandom] = int64(random)
 			}
+		case "DAMAGETYPE":
+			t.As[DamageType] = decode.Keyword(r[field])
+		case "DARK":
+			t.Is |= Dark
 		case "DESCRIPTION":
 			t.As[Description] = string(text.Unfold([]byte(decode.String(data))))
 		case "DOOR":
@@ -313,11 +400,50 @@ func (t *Thing) Unmarshal(r recordjar.Record) {
 					t.As[TriggerType] = "BLOCKER"
 				}
 			}
+		case "DRINK":
+			t.Is |= Drinkable
+			remaining := int64(-1)
+			for k, v := range decode.PairList(r[field]) {
+				b := []byte(v)
+				switch k {
+				case "HYDRATION":
+					t.Int[Hydration] = int64(decode.Integer(b))
+				case "HEAL":
+					t.Int[Heal] = int64(decode.Integer(b))
+				case "SIPS":
+					t.Int[SipsMaximum] = int64(decode.Integer(b))
+				case "REMAINING":
+					remaining = int64(decode.Integer(b))
+				}
+			}
+			if t.Int[SipsMaximum] > 0 {
+				t.Int[Sips] = t.Int[SipsMaximum]
+				if remaining >= 0 && remaining < t.Int[SipsMaximum] {
+					t.Int[Sips] = remaining
+				}
+			}
 		case "EXIT", "EXITS":
 			for name, loc := range decode.PairList(r["EXITS"]) {
 				t.As[DirRefToAs[NameToDir[name]]] = t.As[Zone] + loc
 			}
 			t.Is |= Location
+		case "EXPERIENCE":
+			t.Int[Experience] = int64(decode.Integer(r[field]))
+		case "FACTION":
+			t.Any[Faction] = decode.KeywordList(r[field])
+		case "FOOD":
+			t.Is |= Edible
+			for k, v := range decode.PairList(r[field]) {
+				b := []byte(v)
+				switch k {
+				case "NUTRITION":
+					t.Int[Nutrition] = int64(decode.Integer(b))
+				case "HEAL":
+					t.Int[Heal] = int64(decode.Integer(b))
+				}
+			}
+		case "FOODLEVEL":
+			t.Int[FoodLevel] = int64(decode.Integer(r[field]))
 		case "GENDER":
 			t.As[Gender] = decode.Keyword(r["GENDER"])
 		case "HEALTH":
@@ -343,6 +469,27 @@ func (t *Thing) Unmarshal(r recordjar.Record) {
 			if t.selfHeals() && t.Int[HealthCurrent] == 0 {
 				t.Int[HealthCurrent] = t.Int[HealthMaximum]
 			}
+		case "HIDDEN":
+			t.Int[HiddenChance] = 50
+			t.Int[HiddenReveal] = (5 * time.Minute).Nanoseconds()
+			for k, v := range decode.PairList(r[field]) {
+				b := []byte(v)
+				switch k {
+				case "CHANCE":
+					t.Int[HiddenChance] = int64(decode.Integer(b))
+				case "REVEAL":
+					t.Int[HiddenReveal] = decode.Duration(b).Nanoseconds()
+				case "EXIT", "EXITS":
+					for _, dir := range strings.Split(v, ",") {
+						if _, ok := NameToDir[dir]; ok {
+							t.Any[HiddenExits] = append(t.Any[HiddenExits], dir)
+						}
+					}
+				}
+			}
+			if len(t.Any[HiddenExits]) == 0 {
+				t.Is |= Hidden | _Hidden
+			}
 		case "HOLDABLE":
 			for slot, qty := range decode.PairList(r[field]) {
 				for x := 0; x < decodeInt(qty); x++ {
@@ -355,37 +502,128 @@ func (t *Thing) Unmarshal(r recordjar.Record) {
 			}
 		case "INV", "INVENTORY":
 			t.Is |= Container
+		case "LEVEL":
+			t.Int[Level] = int64(decode.Integer(r[field]))
+		case "LIGHT":
+			t.Is |= Light
+			for k, v := range decode.PairList(r[field]) {
+				b := []byte(v)
+				switch k {
+				case "BURN":
+					t.Int[BurnAfter] = decode.Duration(b).Nanoseconds()
+				case "JITTER":
+					t.Int[BurnJitter] = decode.Duration(b).Nanoseconds()
+				case "DUE_IN", "DUE-IN":
+					t.Int[BurnDueIn] = decode.Duration(b).Nanoseconds()
+				case "LIT":
+					if decode.Boolean(b) {
+						t.Is |= Lit | _Lit
+					}
+				}
+			}
 		case "LOCATION":
 			// Do nothing - only used by loader
-		case "NAME":
-			t.As[Name] = decode.String(data)
-			t.As[UName] = text.TitleFirst(t.As[Name])
-			switch {
-			case strings.HasPrefix(t.As[Name], "a "):
-				t.As[TheName] = "the" + strings.TrimPrefix(t.As[Name], "a")
-				t.As[UTheName] = "The" + strings.TrimPrefix(t.As[Name], "a")
-			case strings.HasPrefix(t.As[Name], "an "):
-				t.As[TheName] = "the" + strings.TrimPrefix(t.As[Name], "an")
-				t.As[UTheName] = "The" + strings.TrimPrefix(t.As[Name], "an")
-			case strings.HasPrefix(t.As[Name], "some "):
-				t.As[TheName] = "the" + strings.TrimPrefix(t.As[Name], "some")
-				t.As[UTheName] = "The" + strings.TrimPrefix(t.As[Name], "some")
-			default:
-				t.As[TheName] = t.As[Name]
-				t.As[UTheName] = t.As[UName]
+		case "LOCK":
+			for field, data := range decode.PairList(r["LOCK"]) {
+				switch field {
+				case "KEY", "KEYS":
+					for _, ref := range strings.Split(data, ",") {
+						switch {
+						case ref == "":
+						case strings.Contains(ref, ":"):
+							t.Any[LockKeys] = append(t.Any[LockKeys], ref)
+						default:
+							t.Any[LockKeys] = append(t.Any[LockKeys], t.As[Zone]+ref)
+						}
+					}
+				case "LOCKED":
+					if decode.Boolean([]byte(data)) {
+						t.Is |= Locked | _Locked
+					}
+				case "RESET":
+					t.Int[TriggerAfter] = decode.Duration([]byte(data)).Nanoseconds()
+				case "JITTER":
+					t.Int[TriggerJitter] = decode.Duration([]byte(data)).Nanoseconds()
+				}
+				if t.Int[TriggerAfter]+t.Int[TriggerJitter] > 0 {
+					t.As[TriggerType] = "BLOCKER"
+				}
+			}
+		case "MANA":
+			mana := decode.PairList(r[field])
+			for k, v := range mana {
+				b := []byte(v)
+				switch k {
+				case "AFTER":
+					t.Int[ManaAfter] = decode.Duration(b).Nanoseconds()
+				case "JITTER":
+					t.Int[ManaJitter] = decode.Duration(b).Nanoseconds()
+				case "DUE_IN", "DUE-IN":
+					t.Int[ManaDueIn] = decode.Duration(b).Nanoseconds()
+				case "CURRENT":
+					t.Int[ManaCurrent] = int64(decode.Integer(b))
+				case "MAXIMUM":
+					t.Int[ManaMaximum] = int64(decode.Integer(b))
+				case "RESTORE":
+					t.Int[ManaRestore] = int64(decode.Integer(b))
+				}
 			}
+
+			// If current mana not set start with full mana
+			if _, ok := mana["CURRENT"]; !ok {
+				t.Int[ManaCurrent] = t.Int[ManaMaximum]
+			}
+		case "MONEY":
+			t.Int[Money] = int64(decode.Integer(r[field]))
+		case "NAME":
+			t.nameAs(decode.String(data))
 		case "NARRATIVE":
 			t.Is |= Narrative
 		case "ONACTION":
 			t.Any[OnAction] = decode.StringList(r["ONACTION"])
+		case "EXITARRIVE":
+			t.Any[ExitArrive] = exitMessages(r["EXITARRIVE"])
+		case "EXITLEAVE":
+			t.Any[ExitLeave] = exitMessages(r["EXITLEAVE"])
+		case "EXITTRAVERSE":
+			t.Any[ExitTraverse] = exitMessages(r["EXITTRAVERSE"])
 		case "ONCOMBAT":
 			t.Any[OnCombat] = decode.StringList(r["ONCOMBAT"])
 		case "ONCLEANUP":
 			t.As[OnCleanup] = decode.String(r["ONCLEANUP"])
+		case "ONDROP":
+			t.Any[OnDrop] = decode.StringList(r["ONDROP"])
+		case "ONENTER":
+			t.Any[OnEnter] = decode.StringList(r["ONENTER"])
+		case "ONGET":
+			t.Any[OnGet] = decode.StringList(r["ONGET"])
+		case "ONLEAVE":
+			t.Any[OnLeave] = decode.StringList(r["ONLEAVE"])
+		case "ONSAY":
+			t.Any[OnSay] = decode.StringList(r["ONSAY"])
 		case "ONRESET":
 			t.As[OnReset] = decode.String(r["ONRESET"])
+		case "PLURAL":
+			t.As[Plural] = decode.String(data)
+		case "PVP":
+			t.Is |= PvP
+		case "PVPCHANGED":
+			t.Int[PvPChanged] = decode.DateTime(r[field]).UnixNano()
+		case "PVPPOLICY":
+			t.As[PvPPolicy] = decode.Keyword(r[field])
+		case "QUANTITY":
+			t.Int[Quantity] = int64(decode.Integer(r[field]))
+			t.Int[QuantityReset] = t.Int[Quantity]
 		case "REF":
 			t.As[Ref] = t.As[Zone] + decode.Keyword(r[field])
+		case "RESISTANCE", "RESISTANCES":
+			for dt, pct := range decode.PairList(r[field]) {
+				p := 100
+				if pct != "" {
+					p = decode.Integer([]byte(pct))
+				}
+				t.Any[Resistance] = append(t.Any[Resistance], dt+"→"+strconv.Itoa(p))
+			}
 		case "RESET":
 			for k, v := range decode.PairList(r[field]) {
 				b := []byte(v)
@@ -409,6 +647,17 @@ func (t *Thing) Unmarshal(r recordjar.Record) {
 			if t.Int[ResetAfter]+t.Int[ResetJitter]+t.Int[ResetDueIn] == 0 {
 				t.Int[ResetAfter] = time.Second.Nanoseconds()
 			}
+		case "SHOP":
+			t.Is |= Shop
+			t.Int[ShopBuy], t.Int[ShopSell] = 50, 100
+			for k, v := range decode.PairList(r[field]) {
+				switch k {
+				case "BUY":
+					t.Int[ShopBuy] = int64(decode.Integer([]byte(v)))
+				case "SELL":
+					t.Int[ShopSell] = int64(decode.Integer([]byte(v)))
+				}
+			}
 		case "START":
 			t.Is |= Start
 		case "VETO", "VETOES":
@@ -420,10 +669,16 @@ func (t *Thing) Unmarshal(r recordjar.Record) {
 					t.As[VetoCombat] = msg
 				case "DROP":
 					t.As[VetoDrop] = msg
+				case "EXTINGUISH":
+					t.As[VetoExtinguish] = msg
 				case "GET":
 					t.As[VetoGet] = msg
 				case "JUNK":
 					t.As[VetoJunk] = msg
+				case "LIGHT":
+					t.As[VetoLight] = msg
+				case "LOCK":
+					t.As[VetoLock] = msg
 				case "OPEN":
 					t.As[VetoOpen] = msg
 				case "PUT":
@@ -436,10 +691,50 @@ func (t *Thing) Unmarshal(r recordjar.Record) {
 					t.As[VetoTake] = msg
 				case "TAKEOUT":
 					t.As[VetoTakeOut] = msg
+				case "UNLOCK":
+					t.As[VetoUnlock] = msg
 				default:
 					//fmt.Printf("Unknown veto: %s, for: %s\n", cmd, t.As[Name])
 				}
 			}
+		case "VALUE":
+			t.Int[Value] = int64(decode.Integer(r[field]))
+		case "WATERLEVEL":
+			t.Int[WaterLevel] = int64(decode.Integer(r[field]))
+		case "WANDER":
+			for k, v := range decode.PairList(r[field]) {
+				b := []byte(v)
+				switch k {
+				case "AFTER":
+					t.Int[WanderAfter] = decode.Duration(b).Nanoseconds()
+				case "JITTER":
+					t.Int[WanderJitter] = decode.Duration(b).Nanoseconds()
+				case "DUE_IN", "DUE-IN":
+					t.Int[WanderDueIn] = decode.Duration(b).Nanoseconds()
+				case "ZONE", "ZONES":
+					if v == "" && t.As[Zone] != "" {
+						t.Any[WanderLimit] = append(t.Any[WanderLimit], t.As[Zone])
+					}
+					for _, zone := range strings.Split(v, ",") {
+						if zone != "" {
+							t.Any[WanderLimit] = append(t.Any[WanderLimit], zone+":")
+						}
+					}
+				case "LOCATION", "LOCATIONS":
+					for _, ref := range strings.Split(v, ",") {
+						switch {
+						case ref == "":
+						case strings.Contains(ref, ":"):
+							t.Any[WanderLimit] = append(t.Any[WanderLimit], ref)
+						default:
+							t.Any[WanderLimit] = append(t.Any[WanderLimit], t.As[Zone]+ref)
+						}
+					}
+				}
+			}
+			if t.Int[WanderAfter]+t.Int[WanderJitter]+t.Int[WanderDueIn] == 0 {
+				t.Int[WanderAfter] = time.Minute.Nanoseconds()
+			}
 		case "WEARABLE":
 			for slot, qty := range decode.PairList(r[field]) {
 				for x := 0; x < decodeInt(qty); x++ {
@@ -450,6 +745,8 @@ func (t *Thing) Unmarshal(r recordjar.Record) {
 			for _, ref := range decode.KeywordList(r[field]) {
 				t.Any[_Wearing] = append(t.Any[_Wearing], t.As[Zone]+ref)
 			}
+		case "WEIGHT":
+			t.Int[Weight] = int64(decode.Integer(r[field]))
 		case "WIELDABLE":
 			for slot, qty := range decode.PairList(r[field]) {
 				for x := 0; x < decodeInt(qty); x++ {
@@ -460,6 +757,8 @@ func (t *Thing) Unmarshal(r recordjar.Record) {
 			for _, ref := range decode.KeywordList(r[field]) {
 				t.Any[_Wielding] = append(t.Any[_Wielding], t.As[Zone]+ref)
 			}
+		case "WIMPY":
+			t.Int[Wimpy] = int64(decode.Integer(r[field]))
 		case "WRITING":
 			t.As[Writing] = decode.String(data)
 		case "ZONELINKS":
@@ -469,6 +768,15 @@ func (t *Thing) Unmarshal(r recordjar.Record) {
 		}
 	}
 
+	// Stacks need names for one and for many items
+	if t.Int[Quantity] > 0 {
+		t.As[Singular] = t.As[Name]
+		if t.As[Plural] == "" {
+			t.As[Plural] = plural(t.As[Name])
+		}
+		t.restack()
+	}
+
 	// If Thing can self heal assume it's an NPC
 	if t.selfHeals() {
 		t.Is |= NPC
@@ -493,8 +801,6 @@ func (t *Thing) Unmarshal(r recordjar.Record) {
 // Marshal saves data from the Thing into the returned Record.
 //
 // BUG(diddymus): Doors save twice as we don't know what side we are on.
-// BUG(diddymus): Live saves don't record a due_in for events. This could be
-// calculated from due_at.
 func (t *Thing) Marshal() recordjar.Record {
 
 	type mss = map[string]string
@@ -569,17 +875,21 @@ func (t *Thing) Marshal() recordjar.Record {
 	}
 
 	vetoes := mss{
-		"Close":   t.As[VetoClose],
-		"Combat":  t.As[VetoCombat],
-		"Drop":    t.As[VetoDrop],
-		"Get":     t.As[VetoGet],
-		"Junk":    t.As[VetoJunk],
-		"Open":    t.As[VetoOpen],
-		"Put":     t.As[VetoPut],
-		"PutIn":   t.As[VetoPutIn],
-		"Read":    t.As[VetoRead],
-		"Take":    t.As[VetoTake],
-		"TakeOut": t.As[VetoTakeOut],
+		"Close":      t.As[VetoClose],
+		"Combat":     t.As[VetoCombat],
+		"Drop":       t.As[VetoDrop],
+		"Extinguish": t.As[VetoExtinguish],
+		"Get":        t.As[VetoGet],
+		"Junk":       t.As[VetoJunk],
+		"Light":      t.As[VetoLight],
+		"Lock":       t.As[VetoLock],
+		"Open":       t.As[VetoOpen],
+		"Put":        t.As[VetoPut],
+		"PutIn":      t.As[VetoPutIn],
+		"Read":       t.As[VetoRead],
+		"Take":       t.As[VetoTake],
+		"TakeOut":    t.As[VetoTakeOut],
+		"Unlock":     t.As[VetoUnlock],
 	}
 	for veto, data := range vetoes {
 		if data == "" {
@@ -602,6 +912,16 @@ func (t *Thing) Marshal() recordjar.Record {
 		}
 		r["Action"] = encode.PairList(action, '→')
 	}
+	if t.Is&Aggressive == Aggressive {
+		aggressive := mss{}
+		if len(t.Any[AggressiveAllow]) > 0 {
+			aggressive["ALLOW"] = strings.ReplaceAll(string(encode.KeywordList(t.Any[AggressiveAllow])), " ", ",")
+		}
+		if len(t.Any[AggressiveDeny]) > 0 {
+			aggressive["DENY"] = strings.ReplaceAll(string(encode.KeywordList(t.Any[AggressiveDeny])), " ", ",")
+		}
+		r["Aggressive"] = encode.PairList(aggressive, '→')
+	}
 	if len(aliases) > 0 {
 		r["Alias"] = encode.KeywordList(aliases)
 	}
@@ -621,6 +941,9 @@ func (t *Thing) Marshal() recordjar.Record {
 	if len(body) > 0 {
 		r["Body"] = encode.PairList(body, '→')
 	}
+	if t.Int[Capacity] > 0 {
+		r["Capacity"] = encode.Integer(int(t.Int[Capacity]))
+	}
 	if t.Int[CleanupAfter]+t.Int[CleanupJitter]+t.Int[CleanupDueIn]+t.Int[CleanupDueAt] > 0 {
 		cleanup := mss{
 			"AFTER":  string(encode.Duration(time.Duration(t.Int[CleanupAfter]))),
@@ -635,17 +958,35 @@ func (t *Thing) Marshal() recordjar.Record {
 		}
 		r["Cleanup"] = encode.PairList(cleanup, '→')
 	}
+	if len(t.Any[Cooldowns]) > 0 {
+		cooldowns := mss{}
+		for _, cd := range t.Any[Cooldowns] {
+			ref, at := splitDue(cd)
+			cooldowns[ref] = time.Unix(0, at).UTC().Format(time.RFC3339)
+		}
+		r["Cooldowns"] = encode.PairList(cooldowns, '→')
+	}
 	if t.Int[DamageFixed] != 0 || t.Int[DamageRandom] != 0 {
 		r["Damage"] = encode.DoubleInteger(
 			int(t.Int[DamageFixed]), int(t.Int[DamageRandom]),
 		)
 	}
+	if _, ok := t.As[DamageType]; ok {
+		r["DamageType"] = encode.Keyword(t.As[DamageType])
+	}
+	if t.Is&Dark == Dark {
+		r["Dark"] = []byte{}
+	}
 	if _, ok := t.As[Description]; ok {
 		r["Description"] = encode.String(t.As[Description])
 	}
 	// BUG(diddymus) This will save the door twice as we don't know which side we
 	// are on.
-	if t.Int[TriggerAfter]+t.Int[TriggerJitter]+t.Int[TriggerDueIn]+t.Int[TriggerDueAt] > 0 {
+	//
+	// A lockable item that is not a door, such as a container, saves its reset
+	// period with its LOCK instead.
+	lockReset := t.As[Blocker] == "" && len(t.Any[LockKeys]) > 0
+	if !lockReset && t.Int[TriggerAfter]+t.Int[TriggerJitter]+t.Int[TriggerDueIn]+t.Int[TriggerDueAt] > 0 {
 		door := mss{
 			"RESET":  string(encode.Duration(time.Duration(t.Int[TriggerAfter]))),
 			"JITTER": string(encode.Duration(time.Duration(t.Int[TriggerJitter]))),
@@ -661,9 +1002,36 @@ func (t *Thing) Marshal() recordjar.Record {
 		}
 		r["Door"] = encode.PairList(door, '→')
 	}
+	if t.Is&Drinkable == Drinkable {
+		drink := mss{
+			"HYDRATION": string(encode.Integer(int(t.Int[Hydration]))),
+			"HEAL":      string(encode.Integer(int(t.Int[Heal]))),
+		}
+		if t.Int[SipsMaximum] > 0 {
+			drink["SIPS"] = string(encode.Integer(int(t.Int[SipsMaximum])))
+			drink["REMAINING"] = string(encode.Integer(int(t.Int[Sips])))
+		}
+		r["Drink"] = encode.PairList(drink, '→')
+	}
 	if len(exits) > 0 {
 		r["Exits"] = encode.PairList(exits, '→')
 	}
+	if t.Int[Experience] > 0 {
+		r["Experience"] = encode.Integer(int(t.Int[Experience]))
+	}
+	if len(t.Any[Faction]) > 0 {
+		r["Faction"] = encode.KeywordList(t.Any[Faction])
+	}
+	if t.Is&Edible == Edible {
+		food := mss{
+			"NUTRITION": string(encode.Integer(int(t.Int[Nutrition]))),
+			"HEAL":      string(encode.Integer(int(t.Int[Heal]))),
+		}
+		r["Food"] = encode.PairList(food, '→')
+	}
+	if _, ok := t.Int[FoodLevel]; ok {
+		r["FoodLevel"] = encode.Integer(int(t.Int[FoodLevel]))
+	}
 	if _, ok := t.As[Gender]; ok {
 		r["Gender"] = encode.String(t.As[Gender])
 	}
@@ -684,6 +1052,16 @@ func (t *Thing) Marshal() recordjar.Record {
 		}
 		r["Health"] = encode.PairList(health, '→')
 	}
+	if t.Is&Hidden == Hidden || len(t.Any[HiddenExits]) > 0 {
+		hidden := mss{
+			"CHANCE": string(encode.Integer(int(t.Int[HiddenChance]))),
+			"REVEAL": string(encode.Duration(time.Duration(t.Int[HiddenReveal]))),
+		}
+		if len(t.Any[HiddenExits]) > 0 {
+			hidden["EXIT"] = strings.Join(t.Any[HiddenExits], ",")
+		}
+		r["Hidden"] = encode.PairList(hidden, '→')
+	}
 	if len(holdable) > 0 {
 		r["Holdable"] = encode.PairList(holdable, '→')
 	}
@@ -693,8 +1071,62 @@ func (t *Thing) Marshal() recordjar.Record {
 	if t.Is&Container == Container || len(inv) > 0 {
 		r["Inventory"] = encode.KeywordList(inv)
 	}
+	if t.Int[Level] > 0 {
+		r["Level"] = encode.Integer(int(t.Int[Level]))
+	}
+	if t.Is&Light == Light {
+		light := mss{
+			"BURN":   string(encode.Duration(time.Duration(t.Int[BurnAfter]))),
+			"JITTER": string(encode.Duration(time.Duration(t.Int[BurnJitter]))),
+			"LIT":    string(encode.Boolean(t.Is&Lit == Lit)),
+		}
+		if at := t.Int[BurnDueIn]; at > 0 {
+			dueIn := time.Duration(at)
+			light["DUE_IN"] = string(encode.Duration(dueIn))
+		} else if at := t.Int[BurnDueAt]; at > 0 {
+			dueIn := time.Unix(0, at).Sub(time.Now())
+			light["DUE_IN"] = string(encode.Duration(dueIn))
+		}
+		r["Light"] = encode.PairList(light, '→')
+	}
 	// LOCATION - n/a?
-	if _, ok := t.As[Name]; ok {
+	if len(t.Any[LockKeys]) > 0 {
+		lock := mss{
+			"KEY":    strings.Join(t.Any[LockKeys], ","),
+			"LOCKED": string(encode.Boolean(t.Is&_Locked == _Locked)),
+		}
+		if lockReset && t.Int[TriggerAfter]+t.Int[TriggerJitter] > 0 {
+			lock["RESET"] = string(encode.Duration(time.Duration(t.Int[TriggerAfter])))
+			lock["JITTER"] = string(encode.Duration(time.Duration(t.Int[TriggerJitter])))
+		}
+		r["Lock"] = encode.PairList(lock, '→')
+	}
+	if _, ok := t.Int[ManaCurrent]; ok {
+		mana := mss{
+			"AFTER":   string(encode.Duration(time.Duration(t.Int[ManaAfter]))),
+			"JITTER":  string(encode.Duration(time.Duration(t.Int[ManaJitter]))),
+			"CURRENT": string(encode.Integer(int(t.Int[ManaCurrent]))),
+			"MAXIMUM": string(encode.Integer(int(t.Int[ManaMaximum]))),
+			"RESTORE": string(encode.Integer(int(t.Int[ManaRestore]))),
+		}
+		if at := t.Int[ManaDueIn]; at > 0 {
+			dueIn := time.Duration(at)
+			mana["DUE_IN"] = string(encode.Duration(dueIn))
+		} else if at := t.Int[ManaDueAt]; at > 0 {
+			dueIn := time.Unix(0, at).Sub(time.Now())
+			mana["DUE_IN"] = string(encode.Duration(dueIn))
+		}
+		r["Mana"] = encode.PairList(mana, '→')
+	}
+	if t.Int[Money] > 0 {
+		r["Money"] = encode.Integer(int(t.Int[Money]))
+	}
+	switch _, ok := t.As[Name]; {
+	case t.Int[Quantity] > 0:
+		r["Name"] = encode.String(t.As[Singular])
+		r["Plural"] = encode.String(t.As[Plural])
+		r["Quantity"] = encode.Integer(int(t.Int[Quantity]))
+	case ok:
 		r["Name"] = encode.String(t.As[Name])
 	}
 	if t.Is&Narrative == Narrative {
@@ -703,15 +1135,40 @@ func (t *Thing) Marshal() recordjar.Record {
 	if _, ok := t.Any[OnAction]; ok {
 		r["OnAction"] = encode.StringList(t.Any[OnAction])
 	}
+	for _, key := range []anyKey{ExitArrive, ExitLeave, ExitTraverse} {
+		msgs := mss{}
+		for x, msg := range t.Any[key] {
+			if msg != "" {
+				msgs[strings.ToUpper(DirToName[North+refKey(x)])] = msg
+			}
+		}
+		if len(msgs) > 0 {
+			r[anyNames[key]] = encode.KeyedStringList(msgs, '→')
+		}
+	}
 	if _, ok := t.As[OnCleanup]; ok {
 		r["OnCleanup"] = encode.String(t.As[OnCleanup])
 	}
 	if _, ok := t.Any[OnCombat]; ok {
 		r["OnCombat"] = encode.StringList(t.Any[OnCombat])
 	}
+	for _, key := range []anyKey{OnDrop, OnEnter, OnGet, OnLeave, OnSay} {
+		if _, ok := t.Any[key]; ok {
+			r[anyNames[key]] = encode.StringList(t.Any[key])
+		}
+	}
 	if _, ok := t.As[OnReset]; ok {
 		r["OnReset"] = encode.String(t.As[OnReset])
 	}
+	if t.Is&PvP == PvP {
+		r["PvP"] = []byte{}
+	}
+	if t.Int[PvPChanged] > 0 {
+		r["PvPChanged"] = encode.DateTime(time.Unix(0, t.Int[PvPChanged]))
+	}
+	if _, ok := t.As[PvPPolicy]; ok {
+		r["PvPPolicy"] = encode.Keyword(t.As[PvPPolicy])
+	}
 	if _, ok := t.As[UID]; ok {
 		r["Ref"] = encode.String(t.As[UID])
 	}
@@ -732,24 +1189,79 @@ func (t *Thing) Marshal() recordjar.Record {
 		}
 		r["Reset"] = encode.PairList(reset, '→')
 	}
+	if len(t.Any[Resistance]) > 0 {
+		resistance := mss{}
+		for _, res := range t.Any[Resistance] {
+			dt, pct := splitResistance(res)
+			resistance[dt] = strconv.FormatInt(pct, 10)
+		}
+		r["Resistance"] = encode.PairList(resistance, '→')
+	}
+	if t.Is&Shop == Shop {
+		shop := mss{
+			"BUY":  string(encode.Integer(int(t.Int[ShopBuy]))),
+			"SELL": string(encode.Integer(int(t.Int[ShopSell]))),
+		}
+		r["Shop"] = encode.PairList(shop, '→')
+	}
 	if t.Is&Start == Start {
 		r["Start"] = []byte{}
 	}
 	if len(vetoes) > 0 {
 		r["Vetoes"] = encode.KeyedStringList(vetoes, '→')
 	}
+	if t.Int[WanderAfter]+t.Int[WanderJitter]+t.Int[WanderDueIn]+t.Int[WanderDueAt] > 0 {
+		wander := mss{
+			"AFTER":  string(encode.Duration(time.Duration(t.Int[WanderAfter]))),
+			"JITTER": string(encode.Duration(time.Duration(t.Int[WanderJitter]))),
+		}
+		if at := t.Int[WanderDueIn]; at > 0 {
+			dueIn := time.Duration(at)
+			wander["DUE_IN"] = string(encode.Duration(dueIn))
+		} else if at := t.Int[WanderDueAt]; at > 0 {
+			dueIn := time.Unix(0, at).Sub(time.Now())
+			wander["DUE_IN"] = string(encode.Duration(dueIn))
+		}
+		var zones, locations []string
+		for _, limit := range t.Any[WanderLimit] {
+			if strings.HasSuffix(limit, ":") {
+				zones = append(zones, strings.TrimSuffix(limit, ":"))
+			} else {
+				locations = append(locations, limit)
+			}
+		}
+		if len(zones) > 0 {
+			wander["ZONE"] = strings.Join(zones, ",")
+		}
+		if len(locations) > 0 {
+			wander["LOCATIONS"] = strings.Join(locations, ",")
+		}
+		r["Wander"] = encode.PairList(wander, '→')
+	}
+	if t.Int[Value] > 0 {
+		r["Value"] = encode.Integer(int(t.Int[Value]))
+	}
+	if _, ok := t.Int[WaterLevel]; ok {
+		r["WaterLevel"] = encode.Integer(int(t.Int[WaterLevel]))
+	}
 	if len(wearable) > 0 {
 		r["Wearable"] = encode.PairList(wearable, '→')
 	}
 	if len(wearing) > 0 {
 		r["Wearing"] = encode.KeywordList(wearing)
 	}
+	if t.Int[Weight] > 0 {
+		r["Weight"] = encode.Integer(int(t.Int[Weight]))
+	}
 	if len(wieldable) > 0 {
 		r["Wieldable"] = encode.PairList(wieldable, '→')
 	}
 	if len(wielding) > 0 {
 		r["Wielding"] = encode.KeywordList(wielding)
 	}
+	if t.Int[Wimpy] > 0 {
+		r["Wimpy"] = encode.Integer(int(t.Int[Wimpy]))
+	}
 	if _, ok := t.As[Writing]; ok {
 		r["Writing"] = encode.String(t.As[Writing])
 	}
@@ -845,6 +1357,10 @@ func (t *Thing) spawn() *Thing {
 	}
 
 	T := t.Copy(false)
+	if T.As[Path] != "" {
+		T.As[Prototype] = T.As[Path]
+		delete(T.As, Path)
+	}
 
 	for ref, item := range t.In {
 		if item.Is&Spawnable == Spawnable {
@@ -904,6 +1420,8 @@ func (t *Thing) Junk() {
 		return
 	}
 
+	forgetCombat(t)
+
 	t.Is &^= Using
 
 	for event := range t.Event {
@@ -948,6 +1466,7 @@ func (t *Thing) Free() {
 	}
 
 	t.Is = Freed
+	forgetCombat(t)
 
 	for eventId := range t.Event {
 		t.Cancel(eventId)
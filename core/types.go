@@ -21,22 +21,34 @@ type (
 
 // Constants for use as bitmasks with the Thing.Is field.
 const (
-	Container isKey = 1 << iota // A container, allows PUT/TAKE
-	Dark                        // A dark location
-	Freed                       // Thing has been freed for GC
-	HasBody                     // Item has a body (Any[Body] can be empty)
-	Holding                     // Item is being held
-	Location                    // Item is a location
-	NPC                         // An NPC
-	Narrative                   // A narrative item
-	Open                        // An open item (e.g. door)
-	Player                      // Is a player
-	Spawnable                   // Is item spawnable?
-	Start                       // A starting location
-	Wait                        // Container reset wait for inventory?
-	Wielding                    // Item is being wielded
-	Wearing                     // Item is being worn
-	_Open                       // Initial open state of item (e.g. door)
+	Aggressive isKey = 1 << iota // NPC attacks players on sight
+	Container                    // A container, allows PUT/TAKE
+	Dark                         // A dark location
+	Drinkable                    // Item can be drunk, allows DRINK
+	Edible                       // Item can be eaten, allows EAT
+	Freed                        // Thing has been freed for GC
+	HasBody                      // Item has a body (Any[Body] can be empty)
+	Hidden                       // Item is hidden until found by SEARCH
+	Holding                      // Item is being held
+	Light                        // Item is a light source
+	Lit                          // A lit light source
+	Location                     // Item is a location
+	Locked                       // A locked item (e.g. door)
+	NPC                          // An NPC
+	Narrative                    // A narrative item
+	Open                         // An open item (e.g. door)
+	Player                       // Is a player
+	PvP                          // Player has PvP turned on
+	Shop                         // NPC is a shopkeeper, allows BUY/SELL
+	Spawnable                    // Is item spawnable?
+	Start                        // A starting location
+	Wait                         // Container reset wait for inventory?
+	Wielding                     // Item is being wielded
+	Wearing                      // Item is being worn
+	_Hidden                      // Initial hidden state of item
+	_Lit                         // Initial lit state of a light source
+	_Locked                      // Initial locked state of item (e.g. door)
+	_Open                        // Initial open state of item (e.g. door)
 )
 
 // Useful masks for groups of constants for checking multiple flags.
@@ -46,21 +58,33 @@ const (
 
 // isNames maps isKey bits to their string name. See also setName method.
 var isNames = []string{
+	"Aggressive",
 	"Container",
 	"Dark",
+	"Drinkable",
+	"Edible",
 	"Freed",
 	"HasBody",
+	"Hidden",
 	"Holding",
+	"Light",
+	"Lit",
 	"Location",
+	"Locked",
 	"NPC",
 	"Narrative",
 	"Open",
 	"Player",
+	"PvP",
+	"Shop",
 	"Spawnable",
 	"Start",
 	"Wait",
 	"Wielding",
 	"Wearing",
+	"_Hidden",
+	"_Lit",
+	"_Locked",
 	"_Open",
 }
 
@@ -96,6 +120,8 @@ const (
 	Account          // MD5 hash of player's account
 	Barrier          // A barrier, value is direction of exit blocked ("E")
 	Blocker          // Name of direction being blocked ("E")
+	BuffName         // Name of the ability buffing a player or NPC
+	DamageType       // Type of damage inflicted ("SLASH")
 	Description      // Item's description
 	DynamicAlias     // "PLAYER" or unset, "SELF" for actor performing a command
 	DynamicQualifier // Situation dependant e.g. GET sets "MY",DROP deleted "MY"
@@ -104,8 +130,13 @@ const (
 	OnCleanup        // Custome cleanup message for an item
 	OnReset          // Custom reset message for an item
 	Password         // Salted SHA512 hash of the account password
+	Path             // Snapshot path of item as loaded from the zone files
+	Plural           // Name of more than one item in a stack ("arrows")
+	Prototype        // Snapshot path of item a spawned copy was made from
+	PvPPolicy        // PvP policy for a location
 	Ref              // Item's original reference (zone:ref or ref)
 	Salt             // Salt used for the account password
+	Singular         // Name of a single item in a stack ("an arrow")
 	StatusSeq        // Escape sequence for writing status updates
 	TheName          // Item's name with a/an/some prefix changed to 'the'
 	TriggerType      // Type of trigger event to send
@@ -115,9 +146,12 @@ const (
 	VetoClose        // Veto CLOSE command
 	VetoCombat       // Veto fighting commands
 	VetoDrop         // Veto for DROP command
+	VetoExtinguish   // Veto EXTINGUISH command
 	VetoGet          // Veto for GET command
 	VetoHold         // Veto HOLD command
 	VetoJunk         // Veto for JUNK command
+	VetoLight        // Veto LIGHT command
+	VetoLock         // Veto LOCK command
 	VetoOpen         // Veto OPEN command
 	VetoPut          // Veto PUT command for item
 	VetoPutIn        // Veto for PUT command into container
@@ -125,6 +159,7 @@ const (
 	VetoRemove       // Veto REMOVE command
 	VetoTake         // Veto TAKE command for item
 	VetoTakeOut      // Veto for TAKE command from container
+	VetoUnlock       // Veto UNLOCK command
 	VetoWear         // Veto WEAR command
 	VetoWield        // Veto WIELD command
 	Writing          // Description of writing on an item
@@ -142,6 +177,8 @@ var asNames = []string{
 	"Account",
 	"Barrier",
 	"Blocker",
+	"BuffName",
+	"DamageType",
 	"Description",
 	"DynamicAlias",
 	"DynamicQualifier",
@@ -150,8 +187,13 @@ var asNames = []string{
 	"OnCleanup",
 	"OnReset",
 	"Password",
+	"Path",
+	"Plural",
+	"Prototype",
+	"PvPPolicy",
 	"Ref",
 	"Salt",
+	"Singular",
 	"StatusSeq",
 	"TheName",
 	"TriggerType",
@@ -161,9 +203,12 @@ var asNames = []string{
 	"VetoClose",
 	"VetoCombat",
 	"VetoDrop",
+	"VetoExtinguish",
 	"VetoGet",
 	"VetoHold",
 	"VetoJunk",
+	"VetoLight",
+	"VetoLock",
 	"VetoOpen",
 	"VetoPut",
 	"VetoPutIn",
@@ -171,6 +216,7 @@ var asNames = []string{
 	"VetoRemove",
 	"VetoTake",
 	"VetoTakeOut",
+	"VetoUnlock",
 	"VetoWear",
 	"VetoWield",
 	"Writing",
@@ -214,21 +260,38 @@ var (
 const (
 	BadAnyKey anyKey = iota
 
-	Alias        // Aliases for an item
-	BarrierAllow // Aliases allowed to pass barrier
-	BarrierDeny  // Aliases denied to pass barrier
-	Body         // Body slots available to an item
-	Holdable     // Body slots required to hold item
-	OnAction     // Actions that can be performed
-	OnCombat     // Combat actions that can be performed
-	Opponents    // UID of opponents being defended against
-	Permissions  // Permissions a player has
-	Qualifier    // Alias qualifiers
-	Wearable     // Body slots required to wear item
-	Wieldable    // Body slots required to wield item
-	_Holding     // UIDs of items initially held
-	_Wearing     // UIDs of items initially worn
-	_Wielding    // UIDs of items initially wielded
+	AggressiveAllow // Aliases not attacked by an aggressive NPC
+	AggressiveDeny  // Aliases attacked by an aggressive NPC
+	Alias           // Aliases for an item
+	BarrierAllow    // Aliases allowed to pass barrier
+	BarrierDeny     // Aliases denied to pass barrier
+	Body            // Body slots available to an item
+	Cooldowns       // Abilities cooling down as "ABILITY→due at"
+	ExitArrive      // Per exit messages for arriving, indexed by direction
+	ExitLeave       // Per exit messages for leaving, indexed by direction
+	ExitTraverse    // Per exit messages for traversing, indexed by direction
+	Faction         // Aliases of allies an NPC will come to the aid of
+	Found           // Hidden items and exits found by SEARCH as "ID→forget at"
+	HiddenExits     // Directions of hidden exits
+	Holdable        // Body slots required to hold item
+	LockKeys        // Refs of keys that can lock and unlock item
+	OnAction        // Actions that can be performed
+	OnCombat        // Combat actions that can be performed
+	OnDrop          // Scripted commands run when item dropped
+	OnEnter         // Scripted commands run when a player enters
+	OnGet           // Scripted commands run when item picked up
+	OnLeave         // Scripted commands run when a player leaves
+	OnSay           // Scripted commands run when a keyword is said
+	Opponents       // UID of opponents being defended against
+	Permissions     // Permissions a player has
+	Qualifier       // Alias qualifiers
+	Resistance      // Damage type resistances as "TYPE→percent"
+	WanderLimit     // Zones and location refs an NPC may wander in
+	Wearable        // Body slots required to wear item
+	Wieldable       // Body slots required to wield item
+	_Holding        // UIDs of items initially held
+	_Wearing        // UIDs of items initially worn
+	_Wielding       // UIDs of items initially wielded
 
 )
 
@@ -236,16 +299,33 @@ const (
 var anyNames = []string{
 	"BadAnyKey",
 
+	"AggressiveAllow",
+	"AggressiveDeny",
 	"Alias",
 	"BarrierAllow",
 	"BarrierDeny",
 	"Body",
+	"Cooldowns",
+	"ExitArrive",
+	"ExitLeave",
+	"ExitTraverse",
+	"Faction",
+	"Found",
+	"HiddenExits",
 	"Holdable",
+	"LockKeys",
 	"OnAction",
 	"OnCombat",
+	"OnDrop",
+	"OnEnter",
+	"OnGet",
+	"OnLeave",
+	"OnSay",
 	"Opponents",
 	"Permissions",
 	"Qualifier",
+	"Resistance",
+	"WanderLimit",
 	"Wearable",
 	"Wieldable",
 	"_Holding",
@@ -260,39 +340,95 @@ const (
 	BadIntKey intKey = iota
 
 	// Events
-	ActionAfter   // How often an action event should occur
-	ActionJitter  // Maximum random delay to add to ActionAfter
-	ActionDueAt   // Time a scheduled Action is due
-	ActionDueIn   // Time remaining for Action
-	CleanupAfter  // How soon a clean-up event should occur
-	CleanupJitter // Maximum random delay to add to CleanupAfter
-	CleanupDueAt  // Time a scheduled clean-up is due
-	CleanupDueIn  // Time remaining for clean-up
-	CombatAfter   // How soon a clean-up event should occur
-	CombatJitter  // Maximum random delay to add to CleanupAfter
-	CombatDueAt   // Time a scheduled clean-up is due
-	CombatDueIn   // Time remaining for clean-up
-	HealthAfter   // How soon a healing event should occur
-	HealthJitter  // Maximum random delay to add to HealthAfter
-	HealthDueAt   // Time a scheduled healing event is due
-	HealthDueIn   // Time remaining for healing event
-	ResetAfter    // How soon a reset event should occur
-	ResetJitter   // Maximum random delay to add to TesetAfter
-	ResetDueAt    // Time a scheduled reset is due
-	ResetDueIn    // Time remaining for reset
-	TriggerAfter  // How soon a trigger should occur
-	TriggerJitter // Maximum random delay to add to trigger
-	TriggerDueAt  // Time a scheduled trigger event is due
-	TriggerDueIn  // Time remaining for trigger event
+	ActionAfter    // How often an action event should occur
+	ActionJitter   // Maximum random delay to add to ActionAfter
+	ActionDueAt    // Time a scheduled Action is due
+	ActionDueIn    // Time remaining for Action
+	BuffAfter      // How long a buff from an ability lasts
+	BuffJitter     // Maximum random time to add to BuffAfter
+	BuffDueAt      // Time a buff is due to wear off
+	BuffDueIn      // Time remaining before a buff wears off
+	BurnAfter      // How long a light source burns for
+	BurnJitter     // Maximum random time to add to BurnAfter
+	BurnDueAt      // Time a light source is due to burn out
+	BurnDueIn      // Time remaining before light source burns out
+	CleanupAfter   // How soon a clean-up event should occur
+	CleanupJitter  // Maximum random delay to add to CleanupAfter
+	CleanupDueAt   // Time a scheduled clean-up is due
+	CleanupDueIn   // Time remaining for clean-up
+	CombatAfter    // How soon a clean-up event should occur
+	CombatJitter   // Maximum random delay to add to CleanupAfter
+	CombatDueAt    // Time a scheduled clean-up is due
+	CombatDueIn    // Time remaining for clean-up
+	CooldownAfter  // How soon the next ability cool-down ends
+	CooldownJitter // Maximum random delay to add to CooldownAfter
+	CooldownDueAt  // Time the next ability cool-down is due to end
+	CooldownDueIn  // Time remaining before the next cool-down ends
+	ForgetAfter    // How long found hidden things stay found
+	ForgetJitter   // Maximum random delay to add to ForgetAfter
+	ForgetDueAt    // Time found hidden things are due to be forgotten
+	ForgetDueIn    // Time remaining before found things are forgotten
+	HealthAfter    // How soon a healing event should occur
+	HealthJitter   // Maximum random delay to add to HealthAfter
+	HealthDueAt    // Time a scheduled healing event is due
+	HealthDueIn    // Time remaining for healing event
+	HungerAfter    // How often a player gets hungrier and thirstier
+	HungerJitter   // Maximum random delay to add to HungerAfter
+	HungerDueAt    // Time a scheduled hunger event is due
+	HungerDueIn    // Time remaining for hunger event
+	ManaAfter      // How soon a mana restoring event should occur
+	ManaJitter     // Maximum random delay to add to ManaAfter
+	ManaDueAt      // Time a scheduled mana restoring event is due
+	ManaDueIn      // Time remaining for mana restoring event
+	ResetAfter     // How soon a reset event should occur
+	ResetJitter    // Maximum random delay to add to TesetAfter
+	ResetDueAt     // Time a scheduled reset is due
+	ResetDueIn     // Time remaining for reset
+	TriggerAfter   // How soon a trigger should occur
+	TriggerJitter  // Maximum random delay to add to trigger
+	TriggerDueAt   // Time a scheduled trigger event is due
+	TriggerDueIn   // Time remaining for trigger event
+	WanderAfter    // How often an NPC wanders to another location
+	WanderJitter   // Maximum random delay to add to WanderAfter
+	WanderDueAt    // Time a scheduled Wander is due
+	WanderDueIn    // Time remaining for Wander
 
 	// Non-events
-	Armour        // Armour rating
-	Created       // Timestamp of when item (player) created
-	DamageFixed   // Fixed amount of damage for an actor/item
-	DamageRandom  // [0-DamageRandom] of random damage for an actor/item
-	HealthCurrent // Current health of a player/mobile
-	HealthMaximum // Maximum health a player/mobile heals up to.
-	HealthRestore // Health restored per healing event
+	Armour           // Armour rating
+	BuffArmour       // Armour added by a buff from an ability
+	BuffDamageFixed  // Fixed damage added by a buff from an ability
+	BuffDamageRandom // Random damage added by a buff from an ability
+	Capacity         // Maximum weight a container, player or location can hold
+	Created          // Timestamp of when item (player) created
+	DamageFixed      // Fixed amount of damage for an actor/item
+	DamageRandom     // [0-DamageRandom] of random damage for an actor/item
+	Experience       // Experience gained by a player
+	FoodLevel        // How well fed a player is, 0 (starving) to 100 (full)
+	Heal             // Health restored by eating or drinking an item
+	HealthCurrent    // Current health of a player/mobile
+	HealthMaximum    // Maximum health a player/mobile heals up to.
+	HealthRestore    // Health restored per healing event
+	HiddenChance     // Percentage chance of SEARCH finding hidden thing
+	HiddenReveal     // How long a found hidden thing stays found
+	Hydration        // Increase in WaterLevel per drink from an item
+	Level            // Experience level of a player
+	ManaCurrent      // Current mana of a player/mobile
+	ManaMaximum      // Maximum mana a player/mobile restores up to
+	ManaRestore      // Mana restored per mana restoring event
+	Money            // Coins carried by a player or NPC
+	Moved            // When a player or NPC last moved, not saved
+	Nutrition        // Increase in FoodLevel for eating an item
+	PvPChanged       // When a player last turned PvP on or off
+	Quantity         // Number of items in a stack, 0 if not stackable
+	QuantityReset    // Number of items in a stack when it resets
+	ShopBuy          // Percentage of value a shopkeeper pays for items
+	ShopSell         // Percentage of value a shopkeeper charges for items
+	Sips             // Number of drinks remaining in a drink container
+	SipsMaximum      // Number of drinks a drink container holds when full
+	Value            // Value of an item in coins
+	WaterLevel       // How well watered a player is, 0 (parched) to 100 (full)
+	Weight           // Weight of an item, excluding anything it contains
+	Wimpy            // Health below which an actor tries to flee combat
 )
 
 // intNames maps intKey values to their string name.
@@ -304,6 +440,14 @@ var intNames = []string{
 	"ActionJitter",
 	"ActionDueAt",
 	"ActionDueIn",
+	"BuffAfter",
+	"BuffJitter",
+	"BuffDueAt",
+	"BuffDueIn",
+	"BurnAfter",
+	"BurnJitter",
+	"BurnDueAt",
+	"BurnDueIn",
 	"CleanupAfter",
 	"CleanupJitter",
 	"CleanupDueAt",
@@ -312,10 +456,26 @@ var intNames = []string{
 	"CombatJitter",
 	"CombatDueAt",
 	"CombatDueIn",
+	"CooldownAfter",
+	"CooldownJitter",
+	"CooldownDueAt",
+	"CooldownDueIn",
+	"ForgetAfter",
+	"ForgetJitter",
+	"ForgetDueAt",
+	"ForgetDueIn",
 	"HealthAfter",
 	"HealthJitter",
 	"HealthDueAt",
 	"HealthDueIn",
+	"HungerAfter",
+	"HungerJitter",
+	"HungerDueAt",
+	"HungerDueIn",
+	"ManaAfter",
+	"ManaJitter",
+	"ManaDueAt",
+	"ManaDueIn",
 	"ResetAfter",
 	"ResetJitter",
 	"ResetDueAt",
@@ -324,15 +484,47 @@ var intNames = []string{
 	"TriggerJitter",
 	"TriggerDueAt",
 	"TriggerDueIn",
+	"WanderAfter",
+	"WanderJitter",
+	"WanderDueAt",
+	"WanderDueIn",
 
 	// Non-events
 	"Armour",
+	"BuffArmour",
+	"BuffDamageFixed",
+	"BuffDamageRandom",
+	"Capacity",
 	"Created",
 	"DamageFixed",
 	"DamageRandom",
+	"Experience",
+	"FoodLevel",
+	"Heal",
 	"HealthCurrent",
 	"HealthMaximum",
 	"HealthRestore",
+	"HiddenChance",
+	"HiddenReveal",
+	"Hydration",
+	"Level",
+	"ManaCurrent",
+	"ManaMaximum",
+	"ManaRestore",
+	"Money",
+	"Moved",
+	"Nutrition",
+	"PvPChanged",
+	"Quantity",
+	"QuantityReset",
+	"ShopBuy",
+	"ShopSell",
+	"Sips",
+	"SipsMaximum",
+	"Value",
+	"WaterLevel",
+	"Weight",
+	"Wimpy",
 }
 
 // Standard offsets for Event related values. Given an eventKey we can add the
@@ -351,22 +543,36 @@ const (
 // After and Jitter values should be consecutive as we assume After = eventKey
 // and Jitter = eventKey+1.
 const (
-	Action  eventKey = eventKey(ActionAfter)
-	Cleanup          = eventKey(CleanupAfter)
-	Combat           = eventKey(CombatAfter)
-	Health           = eventKey(HealthAfter)
-	Reset            = eventKey(ResetAfter)
-	Trigger          = eventKey(TriggerAfter)
+	Action   eventKey = eventKey(ActionAfter)
+	Buff              = eventKey(BuffAfter)
+	Burn              = eventKey(BurnAfter)
+	Cleanup           = eventKey(CleanupAfter)
+	Combat            = eventKey(CombatAfter)
+	Cooldown          = eventKey(CooldownAfter)
+	Forget            = eventKey(ForgetAfter)
+	Health            = eventKey(HealthAfter)
+	Hunger            = eventKey(HungerAfter)
+	Mana              = eventKey(ManaAfter)
+	Reset             = eventKey(ResetAfter)
+	Trigger           = eventKey(TriggerAfter)
+	Wander            = eventKey(WanderAfter)
 )
 
 // eventNames maps eventKey values to their string name.
 var eventNames = map[eventKey]string{
-	Action:  "Action",
-	Cleanup: "Cleanup",
-	Combat:  "Combat",
-	Health:  "Health",
-	Reset:   "Reset",
-	Trigger: "Trigger",
+	Action:   "Action",
+	Buff:     "Buff",
+	Burn:     "Burn",
+	Cleanup:  "Cleanup",
+	Combat:   "Combat",
+	Cooldown: "Cooldown",
+	Forget:   "Forget",
+	Health:   "Health",
+	Hunger:   "Hunger",
+	Mana:     "Mana",
+	Reset:    "Reset",
+	Trigger:  "Trigger",
+	Wander:   "Wander",
 }
 
 // Constants for Thing.Ref keys
@@ -410,35 +616,104 @@ var preferredOrdering = []string{
 	asNames[Zone],
 	"Author",
 	"Disabled",
+	"Include",
+	"Params",
 	asNames[Name],
+	asNames[Plural],
 	anyNames[Alias], "Aliases",
 	Start.setNames(),
+	Dark.setNames(),
+	asNames[PvPPolicy],
 	"Exit", "Exits",
+	anyNames[ExitLeave],
+	anyNames[ExitArrive],
+	anyNames[ExitTraverse],
 	"ZoneLinks",
 	"Barrier",
 	"Door",
+	"Lock",
+	Hidden.setNames(),
 	"Location",
 	asNames[Description],
 	anyNames[Body],
 	asNames[Gender],
 	eventNames[Health],
+	eventNames[Mana],
+	anyNames[Cooldowns],
+	intNames[Wimpy],
+	PvP.setNames(),
+	intNames[PvPChanged],
+	intNames[Level],
+	intNames[Experience],
+	intNames[Money],
+	intNames[FoodLevel],
+	intNames[WaterLevel],
 	intNames[Armour],
 	"Damage",
+	asNames[DamageType],
+	anyNames[Resistance], "Resistances",
+	Aggressive.setNames(),
+	anyNames[Faction],
+	Shop.setNames(),
+	intNames[Capacity],
 	"Inv", "Inventory",
 	Holding.setNames(),
 	Wearing.setNames(),
 	Wielding.setNames(),
 	Narrative.setNames(),
+	intNames[Value],
+	intNames[Weight],
+	intNames[Quantity],
+	"Food",
+	"Drink",
 	anyNames[Holdable],
 	anyNames[Wearable],
 	anyNames[Wieldable],
 	asNames[Writing],
+	Light.setNames(),
 	"Veto", "Vetoes",
 	eventNames[Action],
 	"On" + eventNames[Action],
+	eventNames[Wander],
 	eventNames[Cleanup],
 	"On" + eventNames[Cleanup],
 	"On" + eventNames[Combat],
+	anyNames[OnEnter],
+	anyNames[OnLeave],
+	anyNames[OnSay],
+	anyNames[OnGet],
+	anyNames[OnDrop],
 	eventNames[Reset],
 	"On" + eventNames[Reset],
 }
+
+// PreferredOrdering returns a copy of the preferred ordering of attributes
+// used when things are marshaled. This allows external tools to write
+// records using the same ordering as the server.
+func PreferredOrdering() []string {
+	return append([]string{}, preferredOrdering...)
+}
+
+// listFields are the fields decoded as string lists or keyed string lists
+// when things are unmarshaled. As much as possible the names used are
+// predefined type names.
+var listFields = []string{
+	anyNames[ExitLeave],
+	anyNames[ExitArrive],
+	anyNames[ExitTraverse],
+	"Veto", "Vetoes",
+	anyNames[OnAction],
+	anyNames[OnCombat],
+	anyNames[OnEnter],
+	anyNames[OnLeave],
+	anyNames[OnSay],
+	anyNames[OnGet],
+	anyNames[OnDrop],
+}
+
+// ListFields returns a copy of the names of fields decoded as string lists
+// or keyed string lists when things are unmarshaled. This allows external
+// tools to tell which fields may contain list separators.
+func ListFields() []string {
+	return append([]string{}, listFields...)
+}
@@ -0,0 +1,136 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package core
+
+import (
+	"strconv"
+	"time"
+
+	"code.wolfmud.org/WolfMUD.git/text"
+)
+
+// weight returns the total weight of what, including the weight of anything
+// it contains. For a stack the weight of all of the items in the stack is
+// returned.
+func weight(what *Thing) int64 {
+	total := what.Int[Weight] * quantity(what)
+	for _, item := range what.In {
+		total += weight(item)
+	}
+	return total
+}
+
+// portion returns the weight of count items taken from what. If count is 0,
+// or what is not a stack of more than count items, the weight of what is
+// returned.
+func portion(what *Thing, count int64) int64 {
+	if count == 0 || count >= quantity(what) {
+		return weight(what)
+	}
+	return what.Int[Weight] * count
+}
+
+// load returns the total weight of everything in where's inventory. For a
+// player or NPC this is the weight they are carrying. Players and NPCs at a
+// location are not part of the location's load.
+func load(where *Thing) int64 {
+	total := int64(0)
+	for _, item := range where.In {
+		total += weight(item)
+	}
+	return total
+}
+
+// inside returns true if what is in where's inventory, or is in the
+// inventory of something that is inside where, otherwise false.
+func inside(what, where *Thing) bool {
+	for t := what; t.Ref[Where] != nil; t = t.Ref[Where] {
+		if t.Ref[Where].In[t.As[UID]] != t {
+			return false
+		}
+		if t.Ref[Where] == where {
+			return true
+		}
+	}
+	return false
+}
+
+// fits returns true if what, or count items from the stack what, can be moved
+// into where's inventory without exceeding the capacity of where, or the
+// capacity of anything where is in, otherwise false. Something already holding
+// what does not gain any weight from the move and is not checked. A capacity
+// of 0 is unlimited.
+func fits(what, where *Thing, count int64) bool {
+	add := portion(what, count)
+	for ; where != nil; where = where.Ref[Where] {
+		if inside(what, where) {
+			return true
+		}
+		if capacity := where.Int[Capacity]; capacity > 0 && load(where)+add > capacity {
+			return false
+		}
+		if where.Ref[Where] != nil && where.Ref[Where].In[where.As[UID]] != where {
+			return true
+		}
+	}
+	return true
+}
+
+// burdened returns true if who is carrying more than half of the weight they
+// can carry, otherwise false.
+func burdened(who *Thing) bool {
+	return who.Int[Capacity] > 0 && load(who)*2 > who.Int[Capacity]
+}
+
+// burdenDelay is how long a burdened player or NPC must wait after moving
+// before they can move again.
+const burdenDelay = 3 * time.Second
+
+// winded returns true if who is burdened and moved too recently to move
+// again, otherwise false.
+func winded(who *Thing) bool {
+	return burdened(who) && time.Now().UnixNano()-who.Int[Moved] < int64(burdenDelay)
+}
+
+// overloaded returns true if who is carrying more weight than they can carry,
+// otherwise false. This can happen if who's capacity is reduced, or if the
+// weight of items being carried is increased, after the items were picked up.
+func overloaded(who *Thing) bool {
+	return who.Int[Capacity] > 0 && load(who) > who.Int[Capacity]
+}
+
+// tooHeavy notifies the actor why what, or count items from the stack what,
+// could not be moved into where.
+func (s *state) tooHeavy(what, where *Thing, count int64) {
+	switch {
+	case where == s.actor && portion(what, count) > s.actor.Int[Capacity]:
+		s.Msg(s.actor, text.Bad, what.As[UTheName], " is too heavy for you to carry.")
+	case where == s.actor:
+		s.Msg(s.actor, text.Bad, "You can't carry ", what.As[TheName], " as well, you are carrying too much already.")
+	case where.Is&Location == Location:
+		s.Msg(s.actor, text.Bad, "There is no room here for ", what.As[TheName], ".")
+	default:
+		s.Msg(s.actor, text.Bad, what.As[UTheName], " won't fit into ", where.As[TheName], ".")
+	}
+}
+
+// carrying returns a description of the load who is carrying for use by the
+// INVENTORY command. An empty string is returned if who can carry an
+// unlimited weight.
+func carrying(who *Thing) string {
+	if who.Int[Capacity] == 0 {
+		return ""
+	}
+	msg := "You are carrying " + strconv.FormatInt(load(who), 10) + " of a maximum " +
+		strconv.FormatInt(who.Int[Capacity], 10) + " weight."
+	switch {
+	case overloaded(who):
+		msg += " You are overloaded and cannot move!"
+	case burdened(who):
+		msg += " You are burdened and move slowly."
+	}
+	return msg
+}
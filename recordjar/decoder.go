@@ -0,0 +1,208 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package recordjar
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Decoder reads records from an io.Reader one at a time. Only the record
+// currently being decoded is held in memory, making a Decoder suitable for
+// processing large recordjars.
+type Decoder struct {
+	b        *bufio.Reader
+	freetext string // Field name to use for the free text section
+	err      error  // First error returned by the underlying reader
+	comments bool   // Preserve comments as fields?
+}
+
+// NewDecoder returns a new Decoder that reads records from the passed
+// io.Reader - assuming the data to be in the WolfMUD recordjar format. The
+// freetext string is the field name to use for the free text section.
+func NewDecoder(in io.Reader, freetext string) *Decoder {
+
+	// If not using a buffered Reader, make it buffered
+	b, ok := in.(*bufio.Reader)
+	if !ok {
+		b = bufio.NewReader(in)
+	}
+
+	// Make sure the field name to use for free text section is uppercased
+	return &Decoder{b: b, freetext: strings.ToUpper(freetext)}
+}
+
+// PreserveComments causes the Decoder to keep comments instead of discarding
+// them. Comments immediately preceding a field are stored in the record using
+// the field name prefixed with CommentSection. For example, comments before a
+// Name field would be stored as "//NAME". Any other comments in a record, for
+// example comments after the last field, are stored using just
+// CommentSection as the field name. Records containing only comments are
+// returned instead of being skipped. Comments are stored including their
+// leading "//" delimiter but without any leading white space. Multiple
+// comment lines are separated by a line feed.
+//
+// Comments are not preserved by default as they are usually not needed.
+// Preserving comments is useful for tools that want to rewrite a recordjar
+// without losing any comments.
+func (d *Decoder) PreserveComments() {
+	d.comments = true
+}
+
+// Next reads and returns the next Record from the input. Records that contain
+// only comments, or are empty, are skipped - unless comments are being
+// preserved, see PreserveComments. When there are no more records
+// Next returns a nil Record and io.EOF. If the underlying reader returns an
+// error other than io.EOF any partially decoded Record is returned along with
+// the error. Once an error has been returned all subsequent calls to Next
+// will return a nil Record and the same error.
+//
+// For details of the recordjar format see the separate package documentation.
+func (d *Decoder) Next() (Record, error) {
+
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	var (
+		ok bool
+
+		// Variables for processing current line
+		line    []byte   // current line from Reader
+		startWS bool     // current line starts with whitespace before trimming?
+		tokens  [][]byte // temp vars for name:data pair parsed from line
+		name    string   // current name from line
+		data    []byte   // current data from line
+		field   string   // current field being processed (may differ from name)
+
+		// Some flags to improve code readability
+		noName = false // true if line has no name
+		noData = false // true if line has no data
+		noLine = false // true if line has no name and no data
+
+		pending []byte // comments not yet associated with a field
+	)
+
+	// Setup an initially empty record
+	r := Record{}
+
+	// flush stores any pending comments in the record using the passed name.
+	flush := func(name string) {
+		if len(pending) == 0 {
+			return
+		}
+		if _, ok := r[name]; ok {
+			r[name] = append(r[name], '\n')
+		}
+		r[name] = append(r[name], pending...)
+		pending = nil
+	}
+
+	for d.err == nil {
+		line, d.err = d.b.ReadBytes('\n')
+
+		// If we read no data and find an error continue and let loop exit
+		if len(line) == 0 && d.err != nil {
+			continue
+		}
+
+		// Read and parse current line
+		line = bytes.TrimRightFunc(line, unicode.IsSpace)
+		startWS = bytes.IndexFunc(line, unicode.IsSpace) == 0
+		tokens = splitLine.FindSubmatch(line)
+		name, data = string(bytes.ToUpper(tokens[1])), tokens[2]
+
+		noName = len(name) == 0
+		noData = len(data) == 0
+		noLine = noName && noData
+
+		// Ignore comments found outside of free text section, unless we are
+		// preserving them in which case they are kept until we know where they
+		// belong.
+		if noName && field != FTSection && bytes.HasPrefix(data, comment) {
+			if d.comments {
+				if len(pending) > 0 {
+					pending = append(pending, '\n')
+				}
+				pending = append(pending, data...)
+			}
+			continue
+		}
+
+		// Handle record separator by returning the current Record, if it has any
+		// fields, otherwise reset current field being processed and carry on
+		// with the next record. If a record separator appears after a free text
+		// section there must be no leading white-space before it otherwise it
+		// will be taken for free text.
+		if noName && bytes.Equal(data, rSeparator) {
+			if field != FTSection || (field == FTSection && !startWS) {
+				flush(CommentSection)
+				if len(r) > 0 {
+					r.mergeFreeText(d.freetext)
+					return r, nil
+				}
+				field = ""
+				continue
+			}
+		}
+
+		// If we get a new name and not inside a free text section then store new
+		// name as the current field being processed
+		if !noName && field != FTSection {
+			field = name
+			flush(CommentSection + name)
+		}
+
+		// Switch to free text field if an empty line and we are not already
+		// processing the free text section. If there was no current field being
+		// processed we need to record the blank line so that it is included in the
+		// free text section. This lets us have a record that has only a free text
+		// section and can start with a blank line, which is not counted as a
+		// separator line.
+		if noLine && field != FTSection {
+			flush(CommentSection)
+			if field == "" {
+				r[FTSection] = []byte{}
+			}
+			field = FTSection
+			continue
+		}
+
+		// Handle data as free text if already processing the free text section, or
+		// we have no field - in which case assume we are starting a free text
+		// section
+		if field == FTSection || field == "" {
+			flush(CommentSection)
+			if _, ok := r[FTSection]; ok {
+				r[FTSection] = append(r[FTSection], '\n')
+			}
+			r[FTSection] = append(r[FTSection], line...)
+			field = FTSection
+			continue
+		}
+
+		// Handle field. Append a space before appending text if continuation
+		if _, ok = r[field]; ok {
+			r[field] = append(r[field], ' ')
+		}
+		r[field] = append(r[field], data...)
+	}
+
+	// Return last record if we have one, reporting any real errors
+	flush(CommentSection)
+	if len(r) > 0 {
+		r.mergeFreeText(d.freetext)
+		if d.err == io.EOF {
+			return r, nil
+		}
+		return r, d.err
+	}
+
+	return nil, d.err
+}
@@ -0,0 +1,175 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package recordjar_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "code.wolfmud.org/WolfMUD.git/recordjar"
+)
+
+// TestDecoder_strings makes sure records are returned one at a time and that
+// empty and comment only records are skipped.
+func TestDecoder_strings(t *testing.T) {
+	for _, test := range []struct {
+		data string
+		want Jar
+	}{
+		{"", Jar{}},
+		{"%%\n%%\n%%", Jar{}},
+		{"// Comment\n%%\n// Comment\n%%", Jar{}},
+		{"F1: d1", Jar{Record{"F1": []byte("d1")}}},
+		{"F1: d1\n%%", Jar{Record{"F1": []byte("d1")}}},
+		{
+			"F1: d1\n%%\n%%\nF2: d2\n%%\n// Comment\n%%\nF3: d3\n",
+			Jar{
+				Record{"F1": []byte("d1")},
+				Record{"F2": []byte("d2")},
+				Record{"F3": []byte("d3")},
+			},
+		},
+		{
+			"F1: d1\n\nText\n  %%\n%%\nF2: d2",
+			Jar{
+				Record{"F1": []byte("d1"), "FREETEXT": []byte("Text\n  %%")},
+				Record{"F2": []byte("d2")},
+			},
+		},
+	} {
+		t.Run(test.data, func(t *testing.T) {
+			d := NewDecoder(bytes.NewBufferString(test.data), "freetext")
+			have := Jar{}
+			for r, err := d.Next(); err != io.EOF; r, err = d.Next() {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				have = append(have, r)
+			}
+			compare(t, have, test.want)
+
+			// Make sure we keep getting EOF
+			if r, err := d.Next(); r != nil || err != io.EOF {
+				t.Errorf("after EOF have: %v, %v, want: nil, EOF", r, err)
+			}
+		})
+	}
+}
+
+// TestDecoder_files makes sure a Decoder produces the same records as Read.
+func TestDecoder_files(t *testing.T) {
+	for _, filename := range []string{
+		"benchmark.wrj", "greeting.wrj", "location.wrj", "location-dos.wrj",
+		"ft-embed-comment.wrj", "ft-also-as-field-cont.wrj",
+	} {
+		t.Run(filename, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", filename))
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+
+			want := Read(bytes.NewReader(data), "description")
+
+			have := Jar{}
+			d := NewDecoder(bytes.NewReader(data), "description")
+			for r, err := d.Next(); err == nil; r, err = d.Next() {
+				have = append(have, r)
+			}
+			compare(t, have, want)
+		})
+	}
+}
+
+// errReader returns some data followed by a non-EOF error.
+type errReader struct {
+	data []byte
+}
+
+var errTest = errors.New("test error")
+
+func (e *errReader) Read(p []byte) (int, error) {
+	if len(e.data) == 0 {
+		return 0, errTest
+	}
+	n := copy(p, e.data)
+	e.data = e.data[n:]
+	return n, nil
+}
+
+// TestDecoder_error makes sure errors from the underlying reader are
+// returned along with any partial record.
+func TestDecoder_error(t *testing.T) {
+	d := NewDecoder(&errReader{[]byte("F1: d1\n%%\nF2: d2\n")}, "freetext")
+
+	r, err := d.Next()
+	compare(t, Jar{r}, Jar{Record{"F1": []byte("d1")}})
+	if err != nil {
+		t.Errorf("record 1 have error: %v, want: nil", err)
+	}
+
+	r, err = d.Next()
+	compare(t, Jar{r}, Jar{Record{"F2": []byte("d2")}})
+	if err != errTest {
+		t.Errorf("record 2 have error: %v, want: %v", err, errTest)
+	}
+
+	if r, err = d.Next(); r != nil || err != errTest {
+		t.Errorf("after error have: %v, %v, want: nil, %v", r, err, errTest)
+	}
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	data, err := os.ReadFile(filepath.Join("testdata", "benchmark.wrj"))
+	if err != nil {
+		b.Errorf("%s", err)
+		return
+	}
+
+	for i := 0; i < b.N; i++ {
+		d := NewDecoder(bytes.NewReader(data), "description")
+		for _, err := d.Next(); err == nil; _, err = d.Next() {
+		}
+	}
+}
+
+// TestDecoder_comments makes sure comments are kept when preserving comments.
+func TestDecoder_comments(t *testing.T) {
+	for _, test := range []struct {
+		data string
+		want Jar
+	}{
+		{"// C1\n%%", Jar{Record{"//": []byte("// C1")}}},
+		{
+			"  // C1\n  // C2\nF1: d1\n  // C3\nF2: d2\n  // C4\n%%",
+			Jar{Record{
+				"//F1": []byte("// C1\n// C2"), "F1": []byte("d1"),
+				"//F2": []byte("// C3"), "F2": []byte("d2"),
+				"//": []byte("// C4"),
+			}},
+		},
+		{
+			"F1: d1\n// C1\n\nText\n// Not a comment",
+			Jar{Record{
+				"F1": []byte("d1"), "//": []byte("// C1"),
+				"FREETEXT": []byte("Text\n// Not a comment"),
+			}},
+		},
+	} {
+		t.Run(test.data, func(t *testing.T) {
+			d := NewDecoder(bytes.NewBufferString(test.data), "freetext")
+			d.PreserveComments()
+			have := Jar{}
+			for r, err := d.Next(); err == nil; r, err = d.Next() {
+				have = append(have, r)
+			}
+			compare(t, have, test.want)
+		})
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package recordjar
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+
+	"code.wolfmud.org/WolfMUD.git/text"
+)
+
+// Encoder writes records to an io.Writer one at a time. Only the record
+// currently being encoded is buffered in memory, making an Encoder suitable
+// for writing large recordjars incrementally.
+type Encoder struct {
+	w        io.Writer
+	freetext string            // Normalised field name for free text section
+	order    map[string]int    // Preferred ordering of normalised field names
+	names    map[string]string // Preferred spelling of normalised field names
+	buf      bytes.Buffer      // Temporary buffer for current record
+	padding  []byte            // Slice of spaces re-sliced for variable padding
+}
+
+// NewEncoder returns a new Encoder that writes records to the passed
+// io.Writer. The freetext string is used to specify which field name in a
+// record should be used for the free text section. Ordering is a list of field
+// names specifying the order the fields should appear in within records if
+// present. See Jar.Write for more details.
+func NewEncoder(out io.Writer, freetext string, ordering []string) *Encoder {
+	e := &Encoder{
+		w:        out,
+		freetext: text.TitleFirst(strings.ToLower(freetext)),
+		order:    make(map[string]int),
+		names:    make(map[string]string),
+		padding:  bytes.Repeat(Space, maxLineWidth-fSeparatorLen),
+	}
+	for x, v := range ordering {
+		e.order[text.TitleFirst(strings.ToLower(v))] = x
+		e.names[text.TitleFirst(strings.ToLower(v))] = v
+	}
+	return e
+}
+
+// name returns the field name as it should be written out. If the field is in
+// the preferred ordering its spelling from the ordering is used.
+func (e *Encoder) name(field string) string {
+	if name, ok := e.names[field]; ok {
+		return name
+	}
+	return field
+}
+
+// Encode writes the passed Record to the Encoder's io.Writer followed by a
+// record separator. Records without any valid fields are not written. Any
+// error returned by the underlying io.Writer is returned.
+//
+// For details of the recordjar format see the separate package documentation.
+func (e *Encoder) Encode(rec Record) error {
+
+	norm := make(map[string][]byte, len(rec)) // Copy of rec, normalised keys
+	keys := make([]string, 0, len(rec))       // List of sortable norm keys
+	comments := make(map[string][]byte)       // Comments keyed by normalised field
+	maxFieldLen := 0                          // Longest normalised field name
+
+	// Copy fields from rec to norm but with normalised keys. As we go through
+	// the field names note the length of the longest normalised field name.
+	// Comments are copied to comments, keyed by the normalised name of the
+	// field they precede or "" for any other comments.
+	for field, data := range rec {
+
+		if strings.HasPrefix(field, CommentSection) {
+			field = text.TitleFirst(strings.ToLower(field[len(CommentSection):]))
+			comments[field] = data
+			continue
+		}
+
+		if field == "" { // Ignore invalid empty field name
+			continue
+		}
+
+		field = text.TitleFirst(strings.ToLower(field))
+		norm[field], keys = data, append(keys, field)
+
+		// Ignore field name for free text section as field name never written out
+		if field == e.freetext {
+			continue
+		}
+
+		if l := len(field); l > maxFieldLen {
+			maxFieldLen = l
+		}
+	}
+
+	// Sort keys by preferred order, then alphabetical
+	sort.Slice(keys, func(i, j int) bool {
+		a, aok := e.order[keys[i]]
+		b, bok := e.order[keys[j]]
+		switch {
+		case aok && !bok:
+			return true
+		case !aok && bok:
+			return false
+		case !aok && !bok:
+			return keys[i] < keys[j]
+		default:
+			return a < b
+		}
+	})
+
+	// Comments are indented so that the comment delimiter lines up with the
+	// field name/data separator.
+	indent := e.padding[0:0]
+	if maxFieldLen > 0 && maxFieldLen <= len(e.padding) {
+		indent = e.padding[0 : maxFieldLen-1]
+	}
+	writeComment := func(field string) {
+		for _, l := range bytes.Split(comments[field], LF) {
+			e.buf.Write(indent)
+			e.buf.Write(bytes.TrimSpace(l))
+			e.buf.Write(LF)
+		}
+	}
+
+	// Write out fields for current record in the order given by the sorted keys
+	for _, field := range keys {
+
+		// Ignore the free text section field as it has to be written last
+		if field == e.freetext {
+			continue
+		}
+
+		if _, ok := comments[field]; ok {
+			writeComment(field)
+		}
+
+		// Fold the field data, which will now have network '\r\n' line endings.
+		// Strip the '\r' to get Unix line endings. Finally split the data into
+		// separate lines using `\n` as the delimiter.
+		data := text.Fold(norm[field], maxLineWidth-maxFieldLen-fSeparatorLen)
+		data = bytes.Replace(data, CR, Empty, -1)
+		lines := bytes.Split(data, LF)
+
+		// Write field name, separator, and first data line
+		e.buf.Write(e.padding[0 : maxFieldLen-len(field)])
+		e.buf.WriteString(e.name(field))
+		e.buf.WriteByte(':')
+		if len(lines[0]) != 0 {
+			e.buf.Write(Space)
+			e.buf.Write(lines[0])
+		}
+		e.buf.Write(LF)
+
+		// Write continuation data lines. If a continuation line starts with ": "
+		// then outdent it so that the colon lines up with the field name/data
+		// separator.
+		for _, l := range lines[1:] {
+			if len(l) >= fSeparatorLen && bytes.Equal(l[0:2], fSeparator) {
+				e.buf.Write(e.padding[0:maxFieldLen])
+			} else {
+				e.buf.Write(e.padding[0 : maxFieldLen+fSeparatorLen])
+			}
+			e.buf.Write(l)
+			e.buf.Write(LF)
+		}
+	}
+
+	// Write out any remaining comments, including comments for fields that are
+	// not present in the record.
+	for _, field := range e.sortedComments(comments, norm) {
+		writeComment(field)
+	}
+
+	// Write out the free text section, if we have one.
+	if data, ok := norm[e.freetext]; ok {
+
+		// Write separator line if record has a fields section
+		if len(norm) > 1 {
+			e.buf.Write(LF)
+		}
+
+		data = text.Unfold(data)
+		data = text.Fold(data, maxLineWidth)
+		data = bytes.Replace(data, CR, Empty, -1)
+		e.buf.Write(data)
+		e.buf.Write(LF)
+	}
+
+	// If we have written any fields for the record, write a record separator.
+	if len(norm) > 0 || len(comments) > 0 {
+		e.buf.Write(rSeparator)
+		e.buf.Write(LF)
+	}
+
+	_, err := e.buf.WriteTo(e.w)
+	e.buf.Reset()
+	return err
+}
+
+// sortedComments returns the keys of comments which are not for fields
+// written out from norm. The trailing comments with the key "" are always
+// returned last.
+func (e *Encoder) sortedComments(comments, norm map[string][]byte) []string {
+	keys := []string{}
+	for field := range comments {
+		if _, ok := norm[field]; (!ok || field == e.freetext) && field != "" {
+			keys = append(keys, field)
+		}
+	}
+	sort.Strings(keys)
+	if _, ok := comments[""]; ok {
+		keys = append(keys, "")
+	}
+	return keys
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package recordjar_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "code.wolfmud.org/WolfMUD.git/recordjar"
+)
+
+// TestEncoder_write makes sure an Encoder writes the same output as Jar.Write.
+func TestEncoder_write(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "benchmark.wrj"))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	jar := Read(bytes.NewReader(data), "description")
+	ordering := []string{"Ref", "Name", "Aliases", "Exits"}
+
+	want := &bytes.Buffer{}
+	jar.Write(want, "description", ordering)
+
+	have := &bytes.Buffer{}
+	e := NewEncoder(have, "description", ordering)
+	for _, rec := range jar {
+		if err := e.Encode(rec); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if have.String() != want.String() {
+		t.Errorf("have:\n%s\nwant:\n%s", have, want)
+	}
+}
+
+// TestEncoder_roundtrip makes sure records written by an Encoder are read
+// back the same by a Decoder.
+func TestEncoder_roundtrip(t *testing.T) {
+	want := Jar{
+		Record{"REF": []byte("L1"), "NAME": []byte("Fireplace")},
+		Record{"REF": []byte("L2"), "FT": []byte("A description.")},
+		Record{"FT": []byte("Only a description.")},
+	}
+
+	buf := &bytes.Buffer{}
+	e := NewEncoder(buf, "ft", nil)
+	for _, rec := range want {
+		e.Encode(rec)
+	}
+
+	have := Jar{}
+	d := NewDecoder(buf, "ft")
+	for r, err := d.Next(); err == nil; r, err = d.Next() {
+		have = append(have, r)
+	}
+	compare(t, have, want)
+}
+
+type errWriter struct{}
+
+func (errWriter) Write(p []byte) (int, error) {
+	return 0, errTest
+}
+
+func TestEncoder_error(t *testing.T) {
+	e := NewEncoder(errWriter{}, "ft", nil)
+	if err := e.Encode(Record{"F1": []byte("d1")}); !errors.Is(err, errTest) {
+		t.Errorf("have error: %v, want: %v", err, errTest)
+	}
+}
+
+// TestEncoder_comments makes sure preserved comments and preferred field name
+// spellings are written out.
+func TestEncoder_comments(t *testing.T) {
+	data := "// Header\n%%\n       // C1\n     Ref: L1\n       // C2\n" +
+		"OnAction: d1\n       // C3\n\nText\n%%\n"
+
+	d := NewDecoder(bytes.NewBufferString(data), "ft")
+	d.PreserveComments()
+	buf := &bytes.Buffer{}
+	e := NewEncoder(buf, "ft", []string{"Ref", "OnAction"})
+	for r, err := d.Next(); err == nil; r, err = d.Next() {
+		if err := e.Encode(r); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if have := buf.String(); have != data {
+		t.Errorf("have:\n%s\nwant:\n%s", have, data)
+	}
+}
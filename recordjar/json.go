@@ -0,0 +1,94 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package recordjar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MarshalJSON implements the json.Marshaler interface. A Record is encoded
+// as a JSON object with the field names as keys and the field data as string
+// values. Field data is written out verbatim, without any interpretation, so
+// lists and pairs keep their recordjar encoding. For example:
+//
+//	{"ALIASES": "TAVERN FIREPLACE", "EXITS": "E→L3 SE→L4 S→L2"}
+//
+// Keys are written in sorted order so that the output is stable.
+func (r Record) MarshalJSON() ([]byte, error) {
+	m := make(map[string]string, len(r))
+	for field, data := range r {
+		m[field] = string(data)
+	}
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. The JSON is
+// expected to be an object, as written by MarshalJSON. Field names are
+// uppercased. For convenience, when generating data using external tools,
+// number and boolean values are also accepted and stored as their literal
+// text. Any other values, including null, result in an error.
+func (r *Record) UnmarshalJSON(data []byte) error {
+	m := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	rec := make(Record, len(m))
+	for field, raw := range m {
+		raw = bytes.TrimSpace(raw)
+		switch {
+		case len(raw) > 0 && raw[0] == '"':
+			s := ""
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return err
+			}
+			rec[strings.ToUpper(field)] = []byte(s)
+		case bytes.Equal(raw, []byte("true")), bytes.Equal(raw, []byte("false")),
+			len(raw) > 0 && (raw[0] == '-' || (raw[0] >= '0' && raw[0] <= '9')):
+			rec[strings.ToUpper(field)] = append([]byte{}, raw...)
+		default:
+			return fmt.Errorf("recordjar: field %q has invalid JSON value %s", field, raw)
+		}
+	}
+	*r = rec
+	return nil
+}
+
+// ReadJSON reads a Jar from the specified io.Reader. The JSON is expected to
+// be an array of objects, one object per record, as written by WriteJSON.
+// See Record.UnmarshalJSON for the values accepted.
+func ReadJSON(in io.Reader) (Jar, error) {
+	j := Jar{}
+	if err := json.NewDecoder(in).Decode(&j); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// WriteJSON writes the Jar to the specified io.Writer as a JSON array of
+// objects, one object per record. See Record.MarshalJSON for details of how
+// each record is encoded. The free text section is written as a normal field
+// using the field name it was read with, typically "DESCRIPTION". Reading the
+// output back in using ReadJSON results in the same Jar.
+func (j Jar) WriteJSON(out io.Writer) error {
+	if j == nil {
+		j = Jar{}
+	}
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(j)
+}
@@ -0,0 +1,89 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package recordjar_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "code.wolfmud.org/WolfMUD.git/recordjar"
+)
+
+// roundtripFiles are used to check Jars are the same after being written
+// out and read back in again.
+var roundtripFiles = []string{
+	"benchmark.wrj", "greeting.wrj", "location.wrj", "location-dos.wrj",
+	"ft-embed-blank.wrj", "ft-embed-blank-indent-tab.wrj", "ft-indent-space.wrj",
+	"ft-indent-tab.wrj", "ft-embed-comment.wrj", "ft-also-as-field-cont.wrj",
+	filepath.Join("..", "..", "data", "zones", "zinara.wrj"),
+}
+
+func TestJSON_roundtrip(t *testing.T) {
+	for _, filename := range roundtripFiles {
+		t.Run(filename, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", filename))
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			want := Read(bytes.NewReader(data), "description")
+
+			buf := &bytes.Buffer{}
+			if err := want.WriteJSON(buf); err != nil {
+				t.Fatalf("unexpected write error: %s", err)
+			}
+			have, err := ReadJSON(buf)
+			if err != nil {
+				t.Fatalf("unexpected read error: %s", err)
+			}
+			compare(t, have, want)
+		})
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	for _, test := range []struct {
+		data string
+		want Jar
+		err  bool
+	}{
+		{`[]`, Jar{}, false},
+		{`[{}]`, Jar{Record{}}, false},
+		{
+			`[{"Ref": "L1", "exits": "E→L2 W→L3"}, {"Armour": 20, "Start": true}]`,
+			Jar{
+				Record{"REF": []byte("L1"), "EXITS": []byte("E→L2 W→L3")},
+				Record{"ARMOUR": []byte("20"), "START": []byte("true")},
+			},
+			false,
+		},
+		{`[{"Ref": null}]`, nil, true},
+		{`[{"Ref": ["L1"]}]`, nil, true},
+		{`{"Ref": "L1"}`, nil, true},
+	} {
+		t.Run(test.data, func(t *testing.T) {
+			have, err := ReadJSON(bytes.NewBufferString(test.data))
+			if (err != nil) != test.err {
+				t.Fatalf("have error: %v, want error: %t", err, test.err)
+			}
+			compare(t, have, test.want)
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	j := Jar{Record{"REF": []byte("L1"), "VETO": []byte("COMBAT→<No> & \"fighting\"")}}
+	want := "[\n  {\n    \"REF\": \"L1\",\n    \"VETO\": \"COMBAT→<No> & \\\"fighting\\\"\"\n  }\n]\n"
+
+	buf := &bytes.Buffer{}
+	if err := j.WriteJSON(buf); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if have := buf.String(); have != want {
+		t.Errorf("have:\n%s\nwant:\n%s", have, want)
+	}
+}
@@ -0,0 +1,491 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package recordjar
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// WriteYAML writes the Jar to the specified io.Writer as a YAML sequence of
+// mappings, one mapping per record, preceded by a document start marker.
+// Field names are used as the keys and field data is written out verbatim as
+// string values, without any interpretation, so lists and pairs keep their
+// recordjar encoding. Single line values are always double quoted. Multi-line
+// values, such as free text sections, are written as literal block scalars
+// when possible. For example:
+//
+//	---
+//	- ALIASES: "TAVERN FIREPLACE"
+//	  DESCRIPTION: |-
+//	    You are in the corner of the common room.
+//
+//	    A fire burns merrily.
+//	  REF: "L1"
+//
+// Keys are written in sorted order so that the output is stable. Reading the
+// output back in using ReadYAML results in the same Jar.
+func (j Jar) WriteYAML(out io.Writer) error {
+	buf := &bytes.Buffer{}
+	buf.WriteString("---\n")
+
+	if len(j) == 0 {
+		buf.WriteString("[]\n")
+	}
+
+	for _, r := range j {
+		if len(r) == 0 {
+			buf.WriteString("- {}\n")
+			continue
+		}
+
+		keys := make([]string, 0, len(r))
+		for field := range r {
+			keys = append(keys, field)
+		}
+		sort.Strings(keys)
+
+		for x, field := range keys {
+			if x == 0 {
+				buf.WriteString("- ")
+			} else {
+				buf.WriteString("  ")
+			}
+			if yamlPlainKey.MatchString(field) {
+				buf.WriteString(field)
+			} else {
+				buf.WriteString(strconv.Quote(field))
+			}
+			buf.WriteString(":")
+			writeYAMLValue(buf, r[field])
+		}
+	}
+
+	_, err := buf.WriteTo(out)
+	return err
+}
+
+// yamlPlainKey matches keys that can be written without quoting.
+var yamlPlainKey = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.\-]*$`)
+
+// writeYAMLValue writes data as a YAML value, including the leading space and
+// trailing line feed. Data is written as a literal block scalar if it spans
+// multiple lines and can be represented exactly, otherwise it is written as a
+// double quoted string.
+func writeYAMLValue(buf *bytes.Buffer, data []byte) {
+	lines := bytes.Split(data, LF)
+
+	literal := len(lines) > 1 && utf8.Valid(data)
+	for x, l := range lines {
+		if !literal {
+			break
+		}
+		switch {
+		case x == 0 && (len(l) == 0 || l[0] == ' ' || l[0] == '\t'):
+			literal = false
+		case len(bytes.TrimRight(l, " \t")) != len(l):
+			literal = false
+		case bytes.IndexFunc(l, func(r rune) bool {
+			return r != '\t' && !strconv.IsPrint(r)
+		}) != -1:
+			literal = false
+		}
+	}
+	if literal && len(lines[len(lines)-1]) == 0 {
+		literal = false
+	}
+
+	if !literal {
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.Quote(string(data)))
+		buf.Write(LF)
+		return
+	}
+
+	buf.WriteString(" |-\n")
+	for _, l := range lines {
+		if len(l) > 0 {
+			buf.WriteString("    ")
+			buf.Write(l)
+		}
+		buf.Write(LF)
+	}
+}
+
+// ReadYAML reads a Jar from the specified io.Reader. The YAML is expected to
+// be a sequence of mappings, one mapping per record, as written by WriteYAML.
+// Keys are uppercased and used as field names. All values are treated as
+// strings, stored as their literal text. A null or empty value results in an
+// empty field.
+//
+// Only the subset of YAML needed to represent a Jar is supported. Values may
+// be plain, single quoted or double quoted scalars, or literal '|' and folded
+// '>' block scalars. Comments and document markers are ignored. Nested
+// collections, flow collections, anchors, aliases and tags are not supported
+// and result in an error.
+func ReadYAML(in io.Reader) (Jar, error) {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.ReplaceAll(data, []byte("\r\n"), LF)
+	p := &yamlParser{lines: strings.Split(string(data), "\n")}
+	return p.parse()
+}
+
+// yamlParser holds the state for ReadYAML.
+type yamlParser struct {
+	lines []string
+	pos   int
+}
+
+// errorf returns an error for the current line.
+func (p *yamlParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("recordjar: YAML line %d: %s", p.pos+1, fmt.Sprintf(format, a...))
+}
+
+// skip moves past blank lines, comment lines and document markers. It returns
+// false if there are no more lines.
+func (p *yamlParser) skip() bool {
+	for ; p.pos < len(p.lines); p.pos++ {
+		l := strings.TrimSpace(p.lines[p.pos])
+		if l != "" && l[0] != '#' && l != "---" && l != "..." {
+			return true
+		}
+	}
+	return false
+}
+
+// indent returns the number of leading spaces for the current line.
+func (p *yamlParser) indent() int {
+	l := p.lines[p.pos]
+	return len(l) - len(strings.TrimLeft(l, " "))
+}
+
+func (p *yamlParser) parse() (Jar, error) {
+	j := Jar{}
+
+	if !p.skip() {
+		return j, nil
+	}
+	if strings.TrimSpace(p.lines[p.pos]) == "[]" {
+		p.pos++
+		if p.skip() {
+			return nil, p.errorf("unexpected data after empty sequence")
+		}
+		return j, nil
+	}
+
+	seqIndent := p.indent()
+	for p.skip() {
+		l := p.lines[p.pos]
+		if p.indent() != seqIndent || !isYAMLDash(l[seqIndent:]) {
+			return nil, p.errorf("expected sequence entry '- '")
+		}
+
+		r := Record{}
+		rest := l[seqIndent+1:]
+		keyIndent := seqIndent + 1 + len(rest) - len(strings.TrimLeft(rest, " "))
+
+		switch strings.TrimSpace(rest) {
+		case "{}":
+			p.pos++
+			j = append(j, r)
+			continue
+		case "":
+			p.pos++
+			if !p.skip() || p.indent() <= seqIndent {
+				j = append(j, r)
+				continue
+			}
+			keyIndent = p.indent()
+		}
+
+		// Parse mapping entries, the first may be on the same line as the '-'
+		for first := true; ; first = false {
+			if !first && (!p.skip() || p.indent() != keyIndent) {
+				break
+			}
+			field, data, err := p.entry(keyIndent)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := r[field]; ok {
+				return nil, p.errorf("duplicate key %q", field)
+			}
+			r[field] = data
+		}
+
+		if p.pos < len(p.lines) && p.indent() > seqIndent {
+			return nil, p.errorf("unexpected indentation")
+		}
+		j = append(j, r)
+	}
+
+	return j, nil
+}
+
+// isYAMLDash returns true if s starts with a block sequence entry indicator.
+func isYAMLDash(s string) bool {
+	return s == "-" || strings.HasPrefix(s, "- ")
+}
+
+// entry parses the mapping entry starting at column col of the current line,
+// returning the uppercased key and the value. On return the current line is
+// the line after the entry.
+func (p *yamlParser) entry(col int) (string, []byte, error) {
+	s := p.lines[p.pos][col:]
+
+	var key string
+	switch {
+	case s == "":
+		return "", nil, p.errorf("expected mapping key")
+	case s[0] == '"' || s[0] == '\'':
+		k, rest, err := p.quoted(s)
+		if err != nil {
+			return "", nil, err
+		}
+		key, s = k, rest
+		if !strings.HasPrefix(s, ":") {
+			return "", nil, p.errorf("expected ':' after key")
+		}
+		s = s[1:]
+	default:
+		x := strings.Index(s, ": ")
+		if x == -1 {
+			if !strings.HasSuffix(s, ":") {
+				return "", nil, p.errorf("expected 'key: value'")
+			}
+			x = len(s) - 1
+		}
+		key, s = strings.TrimRight(s[:x], " "), s[x+1:]
+	}
+
+	if s != "" && s[0] != ' ' {
+		return "", nil, p.errorf("expected space after ':'")
+	}
+	key = strings.ToUpper(key)
+	s = strings.TrimSpace(s)
+
+	switch {
+	case s == "", s[0] == '#', s == "~", s == "null", s == "Null", s == "NULL":
+		p.pos++
+		if p.skip() && p.indent() > col {
+			return "", nil, p.errorf("nested collections are not supported")
+		}
+		return key, []byte{}, nil
+	case s[0] == '|' || s[0] == '>':
+		data, err := p.block(s, col)
+		return key, data, err
+	case s[0] == '"' || s[0] == '\'':
+		v, rest, err := p.quoted(s)
+		if err != nil {
+			return "", nil, err
+		}
+		if rest = strings.TrimSpace(rest); rest != "" && rest[0] != '#' {
+			return "", nil, p.errorf("unexpected data after quoted value")
+		}
+		p.pos++
+		return key, []byte(v), nil
+	case strings.ContainsRune("[{&*!@`%", rune(s[0])):
+		return "", nil, p.errorf("unsupported value starting with %q", s[0])
+	}
+
+	return key, p.plain(s, col), nil
+}
+
+// plain parses a plain scalar starting with s. Continuation lines that are
+// indented more than col are folded into the value.
+func (p *yamlParser) plain(s string, col int) []byte {
+	if x := strings.Index(s, " #"); x != -1 {
+		s = strings.TrimSpace(s[:x])
+	}
+	b := []byte(s)
+
+	p.pos++
+	empties := 0
+	for ; p.pos < len(p.lines); p.pos++ {
+		l := strings.TrimSpace(p.lines[p.pos])
+		if l == "" {
+			empties++
+			continue
+		}
+		if p.indent() <= col || l[0] == '#' {
+			break
+		}
+		if x := strings.Index(l, " #"); x != -1 {
+			l = strings.TrimSpace(l[:x])
+		}
+		if empties == 0 {
+			b = append(b, ' ')
+		}
+		b = append(b, bytes.Repeat(LF, empties)...)
+		b = append(b, l...)
+		empties = 0
+	}
+	return b
+}
+
+// quoted parses a single or double quoted scalar at the start of s returning
+// the unquoted value and any remaining text after the closing quote.
+func (p *yamlParser) quoted(s string) (string, string, error) {
+	q := s[0]
+	b := &strings.Builder{}
+	for x := 1; x < len(s); x++ {
+		c := s[x]
+		switch {
+		case c == q && q == '\'' && x+1 < len(s) && s[x+1] == '\'':
+			b.WriteByte('\'')
+			x++
+		case c == q:
+			return b.String(), s[x+1:], nil
+		case c == '\\' && q == '"':
+			n, err := yamlEscape(b, s[x+1:])
+			if err != nil {
+				return "", "", p.errorf("%s", err)
+			}
+			x += n
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", p.errorf("missing closing quote, multi-line quoted values are not supported")
+}
+
+// yamlEscape writes the character for the escape sequence at the start of s,
+// without the leading backslash, to b. It returns the number of bytes of s
+// used.
+func yamlEscape(b *strings.Builder, s string) (int, error) {
+	if s == "" {
+		return 0, fmt.Errorf("invalid escape at end of line")
+	}
+	simple := map[byte]string{
+		'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n",
+		'v': "\v", 'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"",
+		'/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028",
+		'P': "\u2029",
+	}
+	if r, ok := simple[s[0]]; ok {
+		b.WriteString(r)
+		return 1, nil
+	}
+
+	size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[0]]
+	if size == 0 || len(s) < size+1 {
+		return 0, fmt.Errorf("invalid escape %q", "\\"+s[:1])
+	}
+	n, err := strconv.ParseUint(s[1:size+1], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid escape %q", "\\"+s[:size+1])
+	}
+
+	// Go's strconv.Quote writes invalid UTF-8 as \xNN bytes, so treat \x as a
+	// byte in order to round trip values written by WriteYAML.
+	if s[0] == 'x' {
+		b.WriteByte(byte(n))
+	} else {
+		b.WriteRune(rune(n))
+	}
+	return size + 1, nil
+}
+
+// block parses a literal or folded block scalar with the header h. The block
+// content must be indented more than col.
+func (p *yamlParser) block(h string, col int) ([]byte, error) {
+	style, chomp, indent := h[0], byte(0), 0
+	for _, c := range []byte(h[1:]) {
+		switch {
+		case (c == '-' || c == '+') && chomp == 0:
+			chomp = c
+		case c >= '1' && c <= '9' && indent == 0:
+			indent = col + int(c-'0')
+		case c == ' ' || c == '#':
+		default:
+			return nil, p.errorf("invalid block scalar header %q", h)
+		}
+		if c == ' ' || c == '#' {
+			break
+		}
+	}
+
+	// Collect lines of the block, noting trailing empty lines separately
+	p.pos++
+	lines, empties := []string{}, 0
+	for ; p.pos < len(p.lines); p.pos++ {
+		l := p.lines[p.pos]
+		if strings.TrimSpace(l) == "" {
+			empties++
+			continue
+		}
+		if indent == 0 {
+			indent = p.indent()
+			if indent <= col {
+				indent = col + 1
+				break
+			}
+		}
+		if p.indent() < indent {
+			break
+		}
+		for ; empties > 0; empties-- {
+			lines = append(lines, "")
+		}
+		lines = append(lines, l[indent:])
+	}
+
+	var b []byte
+	if style == '|' {
+		b = []byte(strings.Join(lines, "\n"))
+	} else {
+		b = yamlFold(lines)
+	}
+
+	switch {
+	case chomp == '-':
+	case len(lines) == 0 && chomp == '+':
+		b = append(b, bytes.Repeat(LF, empties)...)
+	case len(lines) == 0:
+	case chomp == '+':
+		b = append(b, bytes.Repeat(LF, empties+1)...)
+	default:
+		b = append(b, '\n')
+	}
+	return b, nil
+}
+
+// yamlFold folds the lines of a folded block scalar. Line breaks between
+// lines of text are replaced with a space. Empty lines and lines that are
+// more indented are kept.
+func yamlFold(lines []string) []byte {
+	b := []byte{}
+	empties, prevNormal, started := 0, false, false
+	for _, l := range lines {
+		if l == "" {
+			empties++
+			continue
+		}
+		normal := l[0] != ' ' && l[0] != '\t'
+		switch {
+		case !started:
+			b = append(b, bytes.Repeat(LF, empties)...)
+		case prevNormal && normal && empties == 0:
+			b = append(b, ' ')
+		case prevNormal && normal:
+			b = append(b, bytes.Repeat(LF, empties)...)
+		default:
+			b = append(b, bytes.Repeat(LF, empties+1)...)
+		}
+		b = append(b, l...)
+		started, prevNormal, empties = true, normal, 0
+	}
+	return b
+}
@@ -0,0 +1,110 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package recordjar_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "code.wolfmud.org/WolfMUD.git/recordjar"
+)
+
+func TestYAML_roundtrip(t *testing.T) {
+	for _, filename := range roundtripFiles {
+		t.Run(filename, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", filename))
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			want := Read(bytes.NewReader(data), "description")
+
+			buf := &bytes.Buffer{}
+			if err := want.WriteYAML(buf); err != nil {
+				t.Fatalf("unexpected write error: %s", err)
+			}
+			have, err := ReadYAML(buf)
+			if err != nil {
+				t.Fatalf("unexpected read error: %s", err)
+			}
+			compare(t, have, want)
+		})
+	}
+}
+
+func TestYAML_roundtripValues(t *testing.T) {
+	want := Jar{
+		Record{},
+		Record{
+			"EMPTY":    []byte{},
+			"LEADING":  []byte("  indented\nlines"),
+			"TRAILING": []byte("text\n"),
+			"SPACES":   []byte("line 1  \nline 2"),
+			"CONTROL":  []byte("a\x1bb\r\nc"),
+			"INVALID":  []byte("\xff\xfe"),
+			"UNICODE":  []byte("E→L2  "),
+			"HASH":     []byte("#1 # 2"),
+			"//NAME":   []byte("// A comment"),
+			"FREETEXT": []byte("Line 1\n\n\tLine 3"),
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := want.WriteYAML(buf); err != nil {
+		t.Fatalf("unexpected write error: %s", err)
+	}
+	have, err := ReadYAML(buf)
+	if err != nil {
+		t.Fatalf("unexpected read error: %s\n%s", err, buf)
+	}
+	compare(t, have, want)
+}
+
+func TestReadYAML(t *testing.T) {
+	for _, test := range []struct {
+		data string
+		want Jar
+		err  bool
+	}{
+		{"", Jar{}, false},
+		{"---\n[]\n", Jar{}, false},
+		{"- {}\n-\n", Jar{Record{}, Record{}}, false},
+		{
+			"# Comment\n- Ref: L1 # comment\n  Name: 'It''s a name'\n" +
+				"  Exits: \"E→L2\\tW→L3\"\n  Start:\n  Armour: 20\n",
+			Jar{Record{
+				"REF": []byte("L1"), "NAME": []byte("It's a name"),
+				"EXITS": []byte("E→L2\tW→L3"), "START": []byte{},
+				"ARMOUR": []byte("20"),
+			}},
+			false,
+		},
+		{
+			"-\n    ref: L1\n    description: |\n      Line 1\n\n        Line 3\n\n" +
+				"- ref: L2\n  description: >-\n    Folded\n    text\n\n    Para 2\n",
+			Jar{
+				Record{"REF": []byte("L1"), "DESCRIPTION": []byte("Line 1\n\n  Line 3\n")},
+				Record{"REF": []byte("L2"), "DESCRIPTION": []byte("Folded text\nPara 2")},
+			},
+			false,
+		},
+		{"- Ref: plain\n    continued\n", Jar{Record{"REF": []byte("plain continued")}}, false},
+		{"- Ref: L1\n  Ref: L2\n", nil, true},
+		{"- Ref: [L1, L2]\n", nil, true},
+		{"- Ref:\n    - L1\n", nil, true},
+		{"- Ref: \"L1\n", nil, true},
+		{"Ref: L1\n", nil, true},
+	} {
+		t.Run(test.data, func(t *testing.T) {
+			have, err := ReadYAML(bytes.NewBufferString(test.data))
+			if (err != nil) != test.err {
+				t.Fatalf("have error: %v, want error: %t", err, test.err)
+			}
+			compare(t, have, test.want)
+		})
+	}
+}
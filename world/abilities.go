@@ -0,0 +1,18 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package world
+
+import (
+	"code.wolfmud.org/WolfMUD.git/core"
+)
+
+// loadAbilities reads the abilities players and NPCs can use. If there is no
+// abilities file there are no abilities to use.
+func loadAbilities() {
+	if jar := loadData(cfg.abilitiesPath, "abilities"); jar != nil {
+		core.SetAbilities(jar)
+	}
+}
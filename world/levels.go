@@ -0,0 +1,18 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package world
+
+import (
+	"code.wolfmud.org/WolfMUD.git/core"
+)
+
+// loadLevels reads the player experience levels. If there is no levels file
+// players gain experience but never advance past level 1.
+func loadLevels() {
+	if jar := loadData(cfg.levelsPath, "experience levels"); jar != nil {
+		core.SetLevels(jar)
+	}
+}
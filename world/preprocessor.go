@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"log"
 	"regexp"
+	"sort"
 	"strings"
 
 	"code.wolfmud.org/WolfMUD.git/recordjar"
@@ -21,19 +22,144 @@ type preprocess struct {
 	lookup        map[string]int     // A ref to jar index lookup table
 	findAtRef     func([]byte) []int // Helper function to find @refs
 	recIdx        int                // Index of record in jar being processed
+	name          string             // Ref of the jar's header record
+	templates     templates          // Shared templates available to the jar
+	includes      []*preprocess      // Templates included by the jar, in order
 }
 
+// templates is a set of shared template jars, keyed by the Ref of each jar's
+// header record.
+type templates map[string]*preprocess
+
+// findAtRef is a helper function to find @ref and @template:ref in data, with
+// optional parameters in parentheses such as @ref(name=value).
+var findAtRef = regexp.MustCompile(
+	"(?:@)([\\pL\\d_-]+(?::[\\pL\\d_-]+)?)(\\([^)]*\\))?(?:[^\\pL\\d_-]|$)",
+).FindSubmatchIndex
+
+// findParam is a helper function to find ${PARAM} placeholders in data.
+var findParam = regexp.MustCompile("\\$\\{([\\pL\\d_-]+)\\}")
+
 // PreProcessor runs the pre-processor on the specified Jar, modifying the
 // content of the Jar in the process.
 func PreProcessor(j recordjar.Jar) {
+	preProcessor(j, nil)
+}
+
+// preProcessor runs the pre-processor on the specified Jar, modifying the
+// content of the Jar in the process. The passed templates are available to
+// the Jar using the Include header field and @template:ref references.
+func preProcessor(j recordjar.Jar, t templates) {
 	log.Printf("  Pre-processing")
 
+	p := newPreprocess(j)
+	p.include(t)
+	p.process()
+}
+
+// newPreprocess returns the preprocessor state for the passed jar, with the
+// ref lookup table already built.
+func newPreprocess(j recordjar.Jar) *preprocess {
 	p := &preprocess{
 		Jar:       j,
-		findAtRef: regexp.MustCompile("(?:@)([\\pL\\d_-]+)(?:[^\\pL\\d_-]|$)").FindSubmatchIndex,
+		findAtRef: findAtRef,
+	}
+	if len(j) > 0 {
+		p.name = decode.Keyword(j[0]["REF"])
 	}
 	p.buildRefLookup()
-	p.process()
+	return p
+}
+
+// include resolves the templates listed on the Include field of the jar's
+// header record, making them available when expanding @refs. Templates not
+// found are logged and ignored. If including a template would cause an
+// include loop, the loop is logged and the template is not included.
+func (p *preprocess) include(t templates) {
+	p.templates, p.includes = t, nil
+
+	if len(p.Jar) == 0 {
+		return
+	}
+
+	for _, ref := range includeList(p.Jar[0]["INCLUDE"]) {
+		q, found := t[ref]
+		p.ifLog(!found, "Include not found, ref: %s, include: %s", p.name, ref)
+		if !found {
+			continue
+		}
+		if loop := t.includeLoop(p.name, ref, ""); loop != "" {
+			log.Printf("    Include loop: %s, include: %s, loop: %s", p.name, ref, loop)
+			continue
+		}
+		p.includes = append(p.includes, q)
+		log.Printf("    Including: %s", ref)
+	}
+}
+
+// includeList returns the template refs listed on an Include field. Unlike
+// decode.KeywordList the order of the refs is preserved, as the order
+// templates are included determines the order they are searched.
+func includeList(data []byte) (refs []string) {
+	for _, f := range bytes.Fields(data) {
+		ref, dup := decode.Keyword(f), false
+		for _, r := range refs {
+			dup = dup || r == ref
+		}
+		if !dup {
+			refs = append(refs, ref)
+		}
+	}
+	return
+}
+
+// includeLoop follows the Include fields starting at the template ref. If the
+// start ref is reached the chain of includes forming the loop is returned,
+// otherwise an empty string is returned. Seen is the chain of includes
+// followed so far.
+func (t templates) includeLoop(start, ref, seen string) string {
+	if ref == start {
+		return seen + ref
+	}
+	if strings.Contains(", "+seen, ", "+ref+", ") {
+		return ""
+	}
+	q, ok := t[ref]
+	if !ok || len(q.Jar) == 0 {
+		return ""
+	}
+	for _, inc := range includeList(q.Jar[0]["INCLUDE"]) {
+		if loop := t.includeLoop(start, inc, seen+ref+", "); loop != "" {
+			return loop
+		}
+	}
+	return ""
+}
+
+// find returns the preprocessor state and index of the record for ref. The
+// current jar is searched first, followed by any included templates in the
+// order they were included. A ref of the form TEMPLATE:REF searches only the
+// named template, which does not need to be included.
+func (p *preprocess) find(ref string) (*preprocess, int, bool) {
+	if x := strings.IndexByte(ref, ':'); x != -1 {
+		q, found := p.templates[ref[:x]]
+		p.ifLog(!found, "@ref template not found, @ref: @%s", ref)
+		if !found {
+			return nil, 0, false
+		}
+		return q.find(ref[x+1:])
+	}
+
+	if idx, found := p.lookup[ref]; found {
+		return p, idx, true
+	}
+
+	for _, q := range p.includes {
+		if q, idx, found := q.find(ref); found {
+			return q, idx, true
+		}
+	}
+	return nil, 0, false
 }
 
 // buildRefLookup creates a map for looking up jar record indexes given a
@@ -54,7 +180,7 @@ func (p *preprocess) process() {
 
 	for p.recIdx, rec = range p.Jar {
 		for field, data := range rec {
-			p.Jar[p.recIdx][field] = p.expandAtRef(field, data, "")
+			p.Jar[p.recIdx][field], _ = p.expandAtRef(rec, field, data, "")
 		}
 	}
 }
@@ -108,62 +234,188 @@ func (p *preprocess) process() {
 //
 //	This is a small bag for carrying things in.
 //	%%
-func (p *preprocess) expandAtRef(field string, data []byte, seen string) []byte {
+//
+// If the @ref is not found in the current jar, any templates included using
+// the Include field of the jar's header record are searched in order. An @ref
+// may also be of the form @template:ref to refer to a record in a specific
+// template. For example "Body: @STOCK:HUMANOID".
+//
+// The data being expanded is from the record rec. If an expansion was cut
+// short because an @ref loop was found looped is returned as true, otherwise
+// false.
+func (p *preprocess) expandAtRef(rec recordjar.Record, field string, data []byte, seen string) (expanded []byte, looped bool) {
 
 	// Quickly exit if no @ref possible
 	if len(data) == 0 || bytes.IndexByte(data, '@') == -1 {
-		return data
+		return data, false
 	}
 
 	// Slower quick exit if an @ref not found
 	idx := p.findAtRef(data)
 	if len(idx) == 0 {
-		return data
+		return data, false
 	}
 
 	ref := decode.Keyword(data[idx[2]:idx[3]])
 
+	// Note any parameters and where the @ref ends, including the parameters
+	end, args := idx[3], []byte(nil)
+	if idx[4] != -1 {
+		end, args = idx[5], data[idx[4]+1:idx[5]-1]
+	}
+
 	p.ifLog(field == "REF", "@ref not allowed on REF fields: @%s", ref)
 
-	// If REF field or @ref already seen (infinite loop), remove @ref from a copy
-	// of data and return modified copy.
-	if field == "REF" || p.isSeen(field, ref, seen) {
-		d := make([]byte, len(data)-(len(ref)+1))
-		copy(d, data[:idx[0]])
-		copy(d[idx[0]:], data[idx[3]:])
-		return d
+	// If REF field remove @ref from a copy of data and return modified copy.
+	if field == "REF" {
+		return removeAtRef(data, idx[0], end), false
 	}
 
 	var (
-		sub        []byte // Substitution text to replace @ref with
-		recIdx     int    // Record index in jar @ref references
-		recFound   bool   // Record for @ref found in jar?
-		fieldFound bool   // Field for @ref found in record?
+		sub        []byte      // Substitution text to replace @ref with
+		q          *preprocess // Preprocessor state for jar @ref references
+		recIdx     int         // Record index in jar @ref references
+		recFound   bool        // Record for @ref found in jar?
+		fieldFound bool        // Field for @ref found in record?
+		key        = ref       // @ref qualified with jar found in, for loops
 	)
 
-	// Find @ref within current jar
-	if recIdx, recFound = p.lookup[ref]; recFound {
-		sub, fieldFound = p.Jar[recIdx][field]
+	// Find @ref within current jar or included templates
+	if q, recIdx, recFound = p.find(ref); recFound {
+		sub, fieldFound = q.Jar[recIdx][field]
+		key = q.name + ":" + decode.Keyword(q.Jar[recIdx]["REF"])
+	}
+
+	// If @ref already seen (infinite loop), remove @ref from a copy of data and
+	// return modified copy. The @ref is qualified with the jar it was found in,
+	// so that refs with the same name in a zone and a template are not
+	// mistaken for a loop.
+	if p.isSeen(field, key, seen) {
+		return removeAtRef(data, idx[0], end), true
 	}
 
 	p.ifLog(!recFound, "@ref record not found, field: %s, @ref: @%s", field, ref)
 	p.ifLog(recFound && !fieldFound, "@ref field not found, ref: %s, field: %s", ref, field)
 
-	// expand @ref and store replacement so only expanded once when first seen
-	if recFound && fieldFound && !p.isSeen(field, ref, seen) {
-		sub = p.expandAtRef(field, sub, seen+"@"+ref+", ")
-		p.Jar[recIdx][field] = sub
+	// expand @ref and store replacement so only expanded once when first seen.
+	// If the @ref is in a template it is expanded in the context of the
+	// template, so that @refs in templates refer to the template's records. An
+	// expansion cut short by an @ref loop is not stored, as it depends on the
+	// @refs seen so far and would be wrong for other records using the @ref.
+	if recFound && fieldFound {
+		if q != p {
+			q.recIdx = recIdx
+		}
+		sub, looped = q.expandAtRef(q.Jar[recIdx], field, sub, seen+"@"+key+", ")
+		if !looped {
+			q.Jar[recIdx][field] = sub
+		}
+
+		// Substitute parameters into the expansion. This is done on a copy after
+		// the expansion is stored as each @ref may be given different parameters.
+		sub = p.substitute(rec, q.Jar[recIdx], ref, field, sub, args)
 	}
 
 	// Replace @ref in a copy of the data with its expansion. Expansion may be
 	// empty, e.g. ref or field not found, and will cause the @ref to be removed.
-	d := make([]byte, len(data)+len(sub)-(len(ref)+1))
+	d := make([]byte, idx[0], len(data)+len(sub)-(end-idx[0]))
 	copy(d, data[:idx[0]])
-	copy(d[idx[0]:], sub)
-	copy(d[idx[0]+len(sub):], data[idx[3]:])
+	d = append(d, sub...)
+	d = append(d, data[end:]...)
 
 	// Process this field's data again for additional @refs
-	return p.expandAtRef(field, d, seen)
+	expanded, more := p.expandAtRef(rec, field, d, seen)
+	return expanded, looped || more
+}
+
+// removeAtRef returns a copy of data with the @ref, and any parameters,
+// between start and end removed.
+func removeAtRef(data []byte, start, end int) []byte {
+	d := make([]byte, start, len(data)-(end-start))
+	copy(d, data[:start])
+	return append(d, data[end:]...)
+}
+
+// substitute replaces ${PARAM} placeholders in data, the expansion of an
+// @ref for the given field, with parameter values. Values are taken from the
+// args given with the @ref, which are of the form "name=value, ...". If a
+// parameter is not given in args the default from the Params field of the
+// referenced record rec is used. The Params field is also of the form
+// "name=value, ...", a name without a value declares a parameter that has no
+// default and must be given. Missing parameters are logged and replaced with
+// nothing. If the referenced record has a Params field, args not declared on
+// it are logged.
+//
+// If a parameter is not given in args, but is declared on the Params field of
+// the record host containing the @ref, the placeholder is kept. The parameter
+// is then substituted when the host record is itself used as a template, so
+// that parameters can be passed through nested templates.
+func (p *preprocess) substitute(host, rec recordjar.Record, ref, field string, data, args []byte) []byte {
+
+	if args == nil && bytes.Index(data, []byte("${")) == -1 {
+		return data
+	}
+
+	r := decode.Keyword(p.Jar[p.recIdx]["REF"]) // Record being expanded, for logs
+
+	defaults, _ := parseParams(rec["PARAMS"])
+	passed, _ := parseParams(host["PARAMS"])
+	values, invalid := parseParams(args)
+
+	for _, name := range invalid {
+		p.ifLog(true, "@ref parameter has no value, ref: %s, field: %s, @ref: @%s, parameter: %s", r, field, ref, name)
+	}
+
+	if _, declared := rec["PARAMS"]; declared {
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			_, known := defaults[name]
+			p.ifLog(!known, "@ref parameter unknown, ref: %s, field: %s, @ref: @%s, parameter: %s", r, field, ref, name)
+		}
+	}
+
+	missing := map[string]bool{}
+	return findParam.ReplaceAllFunc(data, func(m []byte) []byte {
+		name := decode.Keyword(m[2 : len(m)-1])
+		if value, ok := values[name]; ok && value != nil {
+			return value
+		}
+		if _, ok := passed[name]; ok {
+			return m
+		}
+		if value, ok := defaults[name]; ok && value != nil {
+			return value
+		}
+		p.ifLog(!missing[name], "@ref parameter missing, ref: %s, field: %s, @ref: @%s, parameter: %s", r, field, ref, name)
+		missing[name] = true
+		return nil
+	})
+}
+
+// parseParams parses a comma separated list of "name=value" parameters into
+// a map of uppercased names and values, white space around names and values
+// is ignored. Names without a value are also returned separately in noValue
+// and stored in the map with a nil value.
+func parseParams(data []byte) (params map[string][]byte, noValue []string) {
+	params = make(map[string][]byte)
+	for _, param := range bytes.Split(data, []byte(",")) {
+		kv := bytes.SplitN(param, []byte("="), 2)
+		name := decode.Keyword(kv[0])
+		switch {
+		case name == "":
+			continue
+		case len(kv) == 1:
+			params[name] = nil
+			noValue = append(noValue, name)
+		default:
+			params[name] = bytes.TrimSpace(kv[1])
+		}
+	}
+	return
 }
 
 // ifLog helper to log given message if the test is true, else does nothing.
@@ -0,0 +1,282 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package world
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"code.wolfmud.org/WolfMUD.git/recordjar"
+	"code.wolfmud.org/WolfMUD.git/recordjar/decode"
+)
+
+// preprocessTest pre-processes the zone using the passed templates, returning
+// the processed zone and anything logged while processing.
+func preprocessTest(zone string, tmpls ...string) (recordjar.Jar, string) {
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	t := templates{}
+	for _, tmpl := range tmpls {
+		jar := recordjar.Read(strings.NewReader(tmpl), "description")
+		t[decode.Keyword(jar[0]["REF"])] = newPreprocess(jar)
+	}
+	for _, q := range t {
+		q.include(t)
+	}
+
+	jar := recordjar.Read(strings.NewReader(zone), "description")
+	preProcessor(jar, t)
+	return jar, buf.String()
+}
+
+// field returns the named field of the record with the given ref.
+func field(jar recordjar.Jar, ref, name string) string {
+	for _, rec := range jar {
+		if decode.Keyword(rec["REF"]) == ref {
+			return string(rec[name])
+		}
+	}
+	return "<no record>"
+}
+
+func TestPreProcessor(t *testing.T) {
+	const stock = `%%
+Ref: STOCK
+%%
+Ref: ITEM
+Reset: AFTER→5m JITTER→5m
+%%
+Ref: BASE
+Reset: @ITEM
+%%
+Ref: PERSON
+Params: name
+Name: ${NAME}
+Alias: PERSON
+%%
+Ref: GUARD
+Params: name, armour=20
+Name: @PERSON
+Alias: @PERSON GUARD
+Armour: ${ARMOUR}
+%%
+Ref: CAPTAIN
+Params: name
+Name: @GUARD(name=captain ${NAME})
+%%
+`
+
+	for _, test := range []struct {
+		name  string
+		zone  string
+		ref   string
+		field string
+		want  string
+	}{
+		{
+			"plain @ref",
+			"%%\nRef: Z\n%%\nRef: A\nReset: @B SPAWN\n%%\nRef: B\nReset: AFTER→1m\n%%",
+			"A", "RESET", "AFTER→1m SPAWN",
+		}, {
+			"nested @ref",
+			"%%\nRef: Z\n%%\nRef: A\nReset: @B\n%%\nRef: B\nReset: @C\n%%\nRef: C\nReset: AFTER→1m\n%%",
+			"A", "RESET", "AFTER→1m",
+		}, {
+			"template @ref",
+			"%%\nRef: Z\nInclude: STOCK\n%%\nRef: A\nReset: @ITEM\n%%",
+			"A", "RESET", "AFTER→5m JITTER→5m",
+		}, {
+			"qualified template @ref",
+			"%%\nRef: Z\n%%\nRef: A\nReset: @STOCK:ITEM\n%%",
+			"A", "RESET", "AFTER→5m JITTER→5m",
+		}, {
+			"zone and template refs with the same name",
+			"%%\nRef: Z\nInclude: STOCK\n%%\nRef: A\nReset: @ITEM\n%%\nRef: ITEM\nReset: @BASE\n%%",
+			"A", "RESET", "AFTER→5m JITTER→5m",
+		}, {
+			"parameter given",
+			"%%\nRef: Z\nInclude: STOCK\n%%\nRef: A\nName: @PERSON(name=a baker)\n%%",
+			"A", "NAME", "a baker",
+		}, {
+			"parameter default",
+			"%%\nRef: Z\nInclude: STOCK\n%%\nRef: A\nArmour: @GUARD\n%%",
+			"A", "ARMOUR", "20",
+		}, {
+			"parameter default overridden",
+			"%%\nRef: Z\nInclude: STOCK\n%%\nRef: A\nArmour: @GUARD(armour=30)\n%%",
+			"A", "ARMOUR", "30",
+		}, {
+			"parameter passed through nested template",
+			"%%\nRef: Z\nInclude: STOCK\n%%\nRef: A\nName: @GUARD(name=a city guard)\n%%",
+			"A", "NAME", "a city guard",
+		}, {
+			"parameter passed on explicitly",
+			"%%\nRef: Z\nInclude: STOCK\n%%\nRef: A\nName: @CAPTAIN(name=Bob)\n%%",
+			"A", "NAME", "captain Bob",
+		}, {
+			"nested template without parameters",
+			"%%\nRef: Z\nInclude: STOCK\n%%\nRef: A\nAlias: @GUARD\n%%",
+			"A", "ALIAS", "PERSON GUARD",
+		}, {
+			"parameter missing",
+			"%%\nRef: Z\nInclude: STOCK\n%%\nRef: A\nName: @PERSON\n%%",
+			"A", "NAME", "",
+		}, {
+			"@ref loop",
+			"%%\nRef: Z\n%%\nRef: A\nName: a @B\n%%\nRef: B\nName: b @A\n%%",
+			"A", "NAME", "a b a ",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			jar, _ := preprocessTest(test.zone, stock)
+			if have := field(jar, test.ref, test.field); have != test.want {
+				t.Errorf("have %q, want %q", have, test.want)
+			}
+		})
+	}
+}
+
+// TestPreProcessorLogs checks problems are, and are not, logged.
+func TestPreProcessorLogs(t *testing.T) {
+	const stock = `%%
+Ref: STOCK
+%%
+Ref: PERSON
+Params: name
+Name: ${NAME}
+%%
+Ref: GUARD
+Params: name
+Name: @PERSON
+%%
+`
+
+	for _, test := range []struct {
+		name string
+		zone string
+		want string
+		not  bool
+	}{
+		{
+			"nested parameter not missing",
+			"%%\nRef: Z\nInclude: STOCK\n%%\nRef: A\nName: @GUARD(name=a city guard)\n%%",
+			"parameter missing", true,
+		}, {
+			"parameter missing",
+			"%%\nRef: Z\nInclude: STOCK\n%%\nRef: A\nName: @PERSON\n%%",
+			"@ref parameter missing, ref: A, field: NAME, @ref: @PERSON, parameter: NAME", false,
+		}, {
+			"parameter unknown",
+			"%%\nRef: Z\nInclude: STOCK\n%%\nRef: A\nName: @PERSON(name=a, age=2)\n%%",
+			"@ref parameter unknown, ref: A, field: NAME, @ref: @PERSON, parameter: AGE", false,
+		}, {
+			"no loop for zone and template refs with the same name",
+			"%%\nRef: Z\nInclude: STOCK\n%%\nRef: GUARD\nName: @STOCK:GUARD(name=a)\n%%",
+			"Loop", true,
+		}, {
+			"@ref loop",
+			"%%\nRef: Z\n%%\nRef: A\nName: @B\n%%\nRef: B\nName: @A\n%%",
+			"@ref Loop: A, field: NAME, loop: @Z:B, @Z:A, @Z:B", false,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, logs := preprocessTest(test.zone, stock)
+			if strings.Contains(logs, test.want) == test.not {
+				t.Errorf("logged %q: %t, want %t\n%s", test.want, !test.not, test.not, logs)
+			}
+		})
+	}
+}
+
+// TestPreProcessorTemplateNotChanged checks that using a template from one
+// zone does not change the template as seen by other zones.
+func TestPreProcessorTemplateNotChanged(t *testing.T) {
+	const stock = `%%
+Ref: STOCK
+%%
+Ref: ITEM
+Reset: AFTER→5m
+%%
+Ref: BASE
+Reset: @ITEM
+%%
+Ref: PERSON
+Params: name
+Name: ${NAME}
+%%
+Ref: GUARD
+Params: name
+Name: @PERSON
+%%
+`
+
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	jar := recordjar.Read(strings.NewReader(stock), "description")
+	tmpl := templates{"STOCK": newPreprocess(jar)}
+	tmpl["STOCK"].include(tmpl)
+
+	for x, zone := range []string{
+		"%%\nRef: Z1\nInclude: STOCK\n%%\nRef: A\nReset: @ITEM\n%%\nRef: ITEM\nReset: @BASE\n%%\nRef: G\nName: @GUARD(name=a)\n%%",
+		"%%\nRef: Z2\nInclude: STOCK\n%%\nRef: A\nReset: @BASE\n%%\nRef: G\nName: @GUARD(name=b)\n%%",
+	} {
+		jar := recordjar.Read(strings.NewReader(zone), "description")
+		preProcessor(jar, tmpl)
+		if have, want := field(jar, "A", "RESET"), "AFTER→5m"; have != want {
+			t.Errorf("zone %d reset: have %q, want %q", x+1, have, want)
+		}
+		if have, want := field(jar, "G", "NAME"), string(rune('a'+x)); have != want {
+			t.Errorf("zone %d name: have %q, want %q", x+1, have, want)
+		}
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	for _, test := range []struct {
+		data    string
+		want    map[string]string
+		noValue []string
+	}{
+		{"", map[string]string{}, nil},
+		{"name", map[string]string{"NAME": "<nil>"}, []string{"NAME"}},
+		{"name=a city guard", map[string]string{"NAME": "a city guard"}, nil},
+		{" name = a , armour=20 ", map[string]string{"NAME": "a", "ARMOUR": "20"}, nil},
+		{"name, alias=GUARD", map[string]string{"NAME": "<nil>", "ALIAS": "GUARD"}, []string{"NAME"}},
+		{"text=a=b", map[string]string{"TEXT": "a=b"}, nil},
+		{",,", map[string]string{}, nil},
+	} {
+		params, noValue := parseParams([]byte(test.data))
+
+		have := map[string]string{}
+		for name, value := range params {
+			if value == nil {
+				have[name] = "<nil>"
+			} else {
+				have[name] = string(value)
+			}
+		}
+
+		if len(have) != len(test.want) {
+			t.Errorf("%q: have %v, want %v", test.data, have, test.want)
+			continue
+		}
+		for name, value := range test.want {
+			if have[name] != value {
+				t.Errorf("%q: %s have %q, want %q", test.data, name, have[name], value)
+			}
+		}
+		if strings.Join(noValue, ",") != strings.Join(test.noValue, ",") {
+			t.Errorf("%q: no value have %v, want %v", test.data, noValue, test.noValue)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 Andrew 'Diddymus' Rolfe. All rights reserved.
+//
+// Use of this source code is governed by the license in the LICENSE file
+// included with the source code.
+
+package world
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.wolfmud.org/WolfMUD.git/core"
+	"code.wolfmud.org/WolfMUD.git/recordjar"
+)
+
+// restoreSnapshot restores the world snapshot, if there is one, after the
+// zones have been loaded. If the configuration says to discard the snapshot
+// it is removed instead and the world is left as loaded from the zone files.
+func restoreSnapshot() {
+
+	if cfg.snapshotDiscard {
+		if err := os.Remove(cfg.snapshotPath); err == nil {
+			log.Printf("Discarded world snapshot: %s", cfg.snapshotPath)
+		}
+		return
+	}
+
+	f, err := os.Open(cfg.snapshotPath)
+	if os.IsNotExist(err) {
+		log.Printf("No world snapshot found: %s", cfg.snapshotPath)
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading world snapshot: %s", err)
+		return
+	}
+	jar := recordjar.Read(f, "")
+	f.Close()
+
+	log.Printf("Restoring world snapshot: %s", cfg.snapshotPath)
+	core.Restore(jar)
+	log.Printf("Restored world snapshot, records: %d", len(jar))
+}
+
+// StartSnapshots starts taking periodic world snapshots. The configured
+// snapshot rate is checked, if zero periodic snapshots are disabled.
+// Otherwise a snapshot is taken by calling SaveSnapshot every cfg.snapshotRate
+// periods.
+func StartSnapshots() {
+	if cfg.snapshotRate == 0 {
+		log.Print("Periodic world snapshots disabled")
+		return
+	}
+
+	log.Printf("Started world snapshots, frequency: %s", cfg.snapshotRate)
+	t := time.NewTicker(cfg.snapshotRate)
+	go func() {
+		for range t.C {
+			SaveSnapshot()
+		}
+	}()
+}
+
+// SaveSnapshot writes a snapshot of the current state of the world. The
+// snapshot is written to a temporary file which then replaces any previous
+// snapshot, so that a failed write does not lose the previous snapshot.
+// SaveSnapshot acquires the BWL and must not be called while holding it.
+func SaveSnapshot() {
+	core.BWL.Lock()
+	jar := core.Snapshot()
+	core.BWL.Unlock()
+
+	writeSnapshot(jar)
+}
+
+// Shutdown quits all of the players in the world, saving them, and then
+// writes a final snapshot of the world. Quitting the players first means the
+// player files and the snapshot agree on where unique items are. Shutdown
+// acquires the BWL and does not release it, so that nothing can change the
+// world before the server exits.
+func Shutdown() {
+	core.BWL.Lock()
+	core.QuitPlayers()
+	writeSnapshot(core.Snapshot())
+}
+
+// writeSnapshot writes the passed snapshot to a temporary file which then
+// replaces any previous snapshot.
+func writeSnapshot(jar recordjar.Jar) {
+	f, err := os.CreateTemp(filepath.Dir(cfg.snapshotPath), "snapshot-*.tmp")
+	if err != nil {
+		log.Printf("Error saving world snapshot: %s", err)
+		return
+	}
+	jar.Write(f, "", core.SnapshotOrdering)
+	if err = f.Close(); err == nil {
+		err = os.Rename(f.Name(), cfg.snapshotPath)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		log.Printf("Error saving world snapshot: %s", err)
+		return
+	}
+	log.Printf("Saved world snapshot, records: %d", len(jar))
+}
@@ -10,6 +10,8 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"time"
 
 	"code.wolfmud.org/WolfMUD.git/config"
 	"code.wolfmud.org/WolfMUD.git/core"
@@ -18,7 +20,13 @@ import (
 )
 
 type pkgConfig struct {
-	zonePath string
+	zonePath        string
+	templatePath    string
+	levelsPath      string
+	abilitiesPath   string
+	snapshotPath    string
+	snapshotRate    time.Duration
+	snapshotDiscard bool
 }
 
 // cfg setup by Config and should be treated as immutable and not changed.
@@ -29,7 +37,13 @@ var cfg pkgConfig
 // the configuration is set it should be treated as immutable an not changed.
 func Config(c config.Config) {
 	cfg = pkgConfig{
-		zonePath: filepath.Join(c.Server.DataPath, "zones", "*.wrj"),
+		zonePath:        filepath.Join(c.Server.DataPath, "zones", "*.wrj"),
+		templatePath:    filepath.Join(c.Server.DataPath, "templates", "*.wrj"),
+		levelsPath:      filepath.Join(c.Server.DataPath, "levels.wrj"),
+		abilitiesPath:   filepath.Join(c.Server.DataPath, "abilities.wrj"),
+		snapshotPath:    filepath.Join(c.Server.DataPath, "snapshot.wrj"),
+		snapshotRate:    c.Snapshot.Rate,
+		snapshotDiscard: c.Snapshot.Discard,
 	}
 }
 
@@ -42,6 +56,82 @@ type taggedThing struct {
 	zoneLinks map[string]string
 }
 
+// loadTemplates reads the shared template jars. Template jars have the same
+// format as zone files, with a header record providing a Ref for the template.
+// Records in template jars are not loaded into the world, they are only used
+// when pre-processing zones that include them or use @template:ref.
+func loadTemplates() templates {
+
+	log.Printf("Loading templates from: %s", cfg.templatePath)
+
+	t := templates{}
+
+	filenames, err := filepath.Glob(cfg.templatePath)
+	if err != nil {
+		log.Printf("Load error: %s\n", err)
+		return t
+	}
+
+	for _, fName := range filenames {
+
+		f, err := os.Open(fName)
+		if err != nil {
+			log.Printf("Load error: %s\n", err)
+			continue
+		}
+		jar := recordjar.Read(f, "DESCRIPTION")
+		f.Close()
+
+		ref := ""
+		if len(jar) > 0 {
+			ref = decode.Keyword(jar[0]["REF"])
+		}
+
+		switch _, dup := t[ref]; {
+		case ref == "":
+			log.Printf("load warning, template header ref not found, skipping: %s\n", fName)
+		case dup:
+			log.Printf("load warning, duplicate template ref %s, skipping: %s\n", ref, fName)
+		default:
+			t[ref] = newPreprocess(jar)
+			log.Printf("Loaded template %s: %s", filepath.Base(fName), ref)
+		}
+	}
+
+	// Resolve includes once all templates are loaded
+	refs := make([]string, 0, len(t))
+	for ref := range t {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	for _, ref := range refs {
+		log.Printf("Resolving template includes: %s", ref)
+		t[ref].include(t)
+	}
+
+	return t
+}
+
+// loadData reads the optional data file at path, returning its records. The
+// passed description, such as "abilities", is used when logging. If the file
+// does not exist, or cannot be read, nil is returned.
+func loadData(path, description string) recordjar.Jar {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		log.Printf("No %s found: %s", description, path)
+		return nil
+	}
+	if err != nil {
+		log.Printf("Error reading %s: %s", description, err)
+		return nil
+	}
+	jar := recordjar.Read(f, "")
+	f.Close()
+
+	log.Printf("Loading %s: %s", description, path)
+	return jar
+}
+
 // Load creates the game world.
 //
 // BUG(diddymus): Load will populate core.World directly as a side effect of
@@ -49,6 +139,8 @@ type taggedThing struct {
 // cause a cyclic import.
 func Load() {
 
+	tmpl := loadTemplates()
+
 	log.Printf("Loading zones from: %s", cfg.zonePath)
 
 	refToUID := make(map[string]string)
@@ -77,6 +169,11 @@ func Load() {
 		zref := decode.String(jar[0]["REF"])
 		zone := decode.String(jar[0]["ZONE"])
 		disabled := decode.Boolean(jar[0]["DISABLED"])
+		pvpPolicy := decode.Keyword(jar[0]["PVPPOLICY"])
+		if pvpPolicy != "" && !core.ValidPvPPolicy(pvpPolicy) {
+			log.Printf("load warning, invalid PvP policy %s, ignoring: %s\n", pvpPolicy, fName)
+			pvpPolicy = ""
+		}
 
 		if disabled {
 			log.Printf("Disabled %s: %s (%s)", filepath.Base(fName), zone, zref)
@@ -84,7 +181,7 @@ func Load() {
 		}
 
 		log.Printf("Loading %s: %s (%s)", filepath.Base(fName), zone, zref)
-		PreProcessor(jar)
+		preProcessor(jar, tmpl)
 		jar = jar[1:]
 
 		// Load everything into temporary store
@@ -152,6 +249,13 @@ func Load() {
 		for _, item := range store {
 			if item.Is&core.Location == core.Location {
 				c := item.Copy(true)
+				if p, ok := c.As[core.PvPPolicy]; ok && !core.ValidPvPPolicy(p) {
+					log.Printf("load warning, invalid PvP policy %s, ignoring: %s\n", p, c.As[core.Ref])
+					delete(c.As, core.PvPPolicy)
+				}
+				if _, ok := c.As[core.PvPPolicy]; !ok && pvpPolicy != "" {
+					c.As[core.PvPPolicy] = pvpPolicy
+				}
 				core.World[c.As[core.UID]] = c
 				if c.Is&core.Start == core.Start {
 					core.WorldStart = append(core.WorldStart, c)
@@ -199,6 +303,10 @@ func Load() {
 		loc.InitOnce(nil)
 	}
 
+	loadLevels()
+	loadAbilities()
+	restoreSnapshot()
+
 	log.Printf("Total world locations: %d, starting locations: %d",
 		len(core.World), len(core.WorldStart))
 	log.Print("Genesis complete")